@@ -2,18 +2,21 @@ package taskqueue
 
 import (
 	"context"
-	"encoding/base64"
+	"encoding"
 	"fmt"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
 // BasicTaskQueue implements a FIFO task queue of string values.
 type BasicTaskQueue struct {
-	Name    string
-	Redis   redis.UniversalClient
-	ctx     context.Context
-	noRetry bool
+	Name  string
+	Redis redis.UniversalClient
+	ctx   context.Context
+	store *store
 }
 
 // NewBasic creates a new BasicTaskQueue instance.
@@ -22,32 +25,26 @@ func NewBasic(name string, option ...Option) (*BasicTaskQueue, error) {
 	if err != nil {
 		return nil, err
 	}
-	redisOptions := &redis.Options{
-		Addr:         options.Host,
-		Username:     options.Username,
-		Password:     options.Password,
-		TLSConfig:    options.TLSConfig,
-		ReadTimeout:  options.Timeout,
-		WriteTimeout: options.Timeout,
-		Network:      "tcp",
-	}
-	redisClient := redis.NewClient(redisOptions)
-	_, err = redisClient.Ping(options.Context).Result()
+	redisClient, err := newRedisClient(options)
 	if err != nil {
 		return nil, err
 	}
 	taskQueue := &BasicTaskQueue{
-		Name:    name,
-		Redis:   redisClient,
-		ctx:     options.Context,
-		noRetry: options.NoRetry,
+		Name:  name,
+		Redis: redisClient,
+		ctx:   options.Context,
+		store: newStore(redisClient, name, options),
+	}
+	err = taskQueue.store.register(QueueTypeBasic, basicContentType)
+	if err != nil {
+		return nil, err
 	}
 	return taskQueue, nil
 }
 
 // Size returns the number of items in the queue.
 func (q *BasicTaskQueue) Size() uint64 {
-	zcard := q.Redis.LLen(q.ctx, q.Name)
+	zcard := q.Redis.LLen(q.ctx, q.store.queue)
 	size, err := zcard.Uint64()
 	if err != nil {
 		return 0
@@ -56,23 +53,60 @@ func (q *BasicTaskQueue) Size() uint64 {
 }
 
 // Pop removes and returns the first task from the queue. If the queue is empty, the return value
-// will be nil.
+// will be nil. A task that can't be read, such as one that fails to decompress, is handled the same
+// way as a JSONTaskQueue task that fails to unmarshal, and nil is returned.
 func (q *BasicTaskQueue) Pop() *string {
-	pop := q.Redis.LPop(q.ctx, q.Name)
-	value, err := pop.Result()
+	popped, err := q.store.pop()
+	if err != nil {
+		return nil
+	}
+	payload, env, err := q.store.open(popped)
 	if err != nil {
+		q.store.popFailed(popped, env, err)
 		return nil
 	}
+	value := string(payload)
 	return &value
 }
 
-// Has determines if a queue has an given task.
-func (q *BasicTaskQueue) Has(value string) bool {
-	count, err := q.Redis.LPosCount(q.ctx, q.Name, value, 0, redis.LPosArgs{}).Result()
+// PopN removes and returns up to n tasks from the front of the queue in a single round trip. If the
+// queue is empty, an empty slice is returned. If some tasks can't be read, the rest are returned
+// along with a *BatchError, and the tasks that failed are left empty.
+func (q *BasicTaskQueue) PopN(n int) ([]string, error) {
+	popped, err := q.store.popN(n)
 	if err != nil {
-		return false
+		return nil, err
 	}
-	return len(count) > 0
+	values := make([]string, len(popped))
+	err = q.store.decodeBatch(popped, true, func(i int, payload []byte) error {
+		values[i] = string(payload)
+		return nil
+	})
+	return values, err
+}
+
+// BlockingPop removes and returns the first task from the queue, waiting for a task to be added if
+// the queue is empty. If no task arrives before the timeout elapses, ErrTimeout is returned. If ctx
+// is cancelled first, the context's error is returned. A timeout of zero waits indefinitely. Redis
+// waits in steps of one second, so a timeout or cancellation may take up to a second longer to be
+// noticed. If the task can't be read, such as one that fails to decrypt, the error is returned, even
+// if the task was re-added to the queue.
+func (q *BasicTaskQueue) BlockingPop(ctx context.Context, timeout time.Duration) (string, error) {
+	popped, err := q.store.blockingPop(ctx, timeout)
+	if err != nil {
+		return "", err
+	}
+	payload, env, err := q.store.open(popped)
+	if err != nil {
+		return "", q.store.undelivered(popped, env, err)
+	}
+	return string(payload), nil
+}
+
+// Has determines if a queue has an given task.
+func (q *BasicTaskQueue) Has(value string) bool {
+	has, err := q.store.has([]byte(value))
+	return err == nil && has
 }
 
 // Add adds any number of tasks to the queue in order. Items provided will be marshaled to JSON.
@@ -80,7 +114,17 @@ func (q *BasicTaskQueue) Add(tasks ...any) error {
 	if len(tasks) == 0 {
 		return nil
 	}
-	added, err := q.Redis.RPush(q.ctx, q.Name, tasks...).Result()
+	if q.store.transforms() {
+		bTasks, err := q.marshal(tasks)
+		if err != nil {
+			return err
+		}
+		tasks = make([]any, len(bTasks))
+		for i, bTask := range bTasks {
+			tasks[i] = bTask
+		}
+	}
+	added, err := q.Redis.RPush(q.ctx, q.store.queue, tasks...).Result()
 	if err != nil {
 		return err
 	}
@@ -90,52 +134,241 @@ func (q *BasicTaskQueue) Add(tasks ...any) error {
 	return nil
 }
 
+// AddUnique adds a task to the queue unless a task added with the same key is still pending, and
+// determines if it was added. The key is released once the task is popped, removed, or acknowledged,
+// after which a task with the same key may be added again. The task is stored in an envelope, even
+// if envelopes aren't enabled. While such tasks are pending, Has and Remove also read every stored
+// value that may be an envelope and compare it on the client, which is slower than the lookup Redis
+// does on its own for tasks stored without one.
+func (q *BasicTaskQueue) AddUnique(key string, task any) (bool, error) {
+	payload, err := basicValue(task)
+	if err != nil {
+		return false, err
+	}
+	return q.store.addUnique(key, payload)
+}
+
+// AddWithTTL adds any number of tasks to the queue in order, which expire once ttl has elapsed.
+// Expired tasks are discarded instead of being popped or reserved, or moved to the expired queue if
+// one is set with WithExpiredQueue. The tasks are stored in envelopes, even if envelopes aren't
+// enabled, so Has and Remove only find them if envelopes are enabled with WithEnvelope.
+func (q *BasicTaskQueue) AddWithTTL(ttl time.Duration, tasks ...any) error {
+	payloads := make([][]byte, 0, len(tasks))
+	for _, task := range tasks {
+		payload, err := basicValue(task)
+		if err != nil {
+			return err
+		}
+		payloads = append(payloads, payload)
+	}
+	return q.store.addWithTTL(ttl, payloads)
+}
+
+// AddAt adds any number of tasks to the queue in order once the given time is reached. Until then,
+// the tasks are not included in Size, and are counted by ScheduledSize instead.
+func (q *BasicTaskQueue) AddAt(at time.Time, tasks ...any) error {
+	bTasks, err := q.marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return q.store.schedule(at, bTasks)
+}
+
+// AddIn adds any number of tasks to the queue in order once the given duration has elapsed.
+func (q *BasicTaskQueue) AddIn(delay time.Duration, tasks ...any) error {
+	return q.AddAt(time.Now().Add(delay), tasks...)
+}
+
+// ScheduledSize returns the number of tasks added with AddAt or AddIn that are not yet due.
+func (q *BasicTaskQueue) ScheduledSize() uint64 {
+	return q.store.scheduledSize()
+}
+
+// Promote adds every scheduled task that is due to the queue and returns the number of tasks
+// added. Due tasks are promoted automatically before tasks are popped or reserved, so calling
+// Promote is only necessary to keep Size accurate.
+func (q *BasicTaskQueue) Promote() (int, error) {
+	return q.store.promote()
+}
+
 // Remove removes a task from the queue.
 func (q *BasicTaskQueue) Remove(task any) error {
-	_, err := q.Redis.LRem(q.ctx, q.Name, 0, task).Result()
+	payload, err := basicValue(task)
 	if err != nil {
 		return err
 	}
-	return nil
+	return q.store.remove(payload)
 }
 
-// Clear removes all tasks from the queue.
+// Clear removes all tasks from the queue, including scheduled tasks.
 func (q *BasicTaskQueue) Clear() error {
-	_, err := q.Redis.Del(q.ctx, q.Name).Result()
+	_, err := q.Redis.Del(q.ctx, q.store.queue, q.store.scheduled, q.store.unique).Result()
 	return err
 }
 
 // Get retrieves an item from the queue based on its index.
 func (q *BasicTaskQueue) Get(index int64) (string, error) {
-	value, err := q.Redis.LIndex(q.ctx, q.Name, index).Result()
+	value, err := q.Redis.LIndex(q.ctx, q.store.queue, index).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return "", nil
 		}
 		return "", err
 	}
-	return value, nil
+	payload, _, err := q.store.open(value)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
 }
 
-func (q *BasicTaskQueue) RemoveIndex(index int64) error {
-	value, err := q.Redis.LIndex(q.ctx, q.Name, index).Result()
+// Peek returns the first task of the queue without removing it. If the queue is empty, ErrEmpty is
+// returned.
+func (q *BasicTaskQueue) Peek() (string, error) {
+	value, err := q.store.peek()
 	if err != nil {
-		if err == redis.Nil {
-			return fmt.Errorf("index %d does not exist in queue", index)
-		}
-		return err
+		return "", err
 	}
-	encoded := base64.StdEncoding.EncodeToString([]byte(value))
-	_, err = q.Redis.LSet(q.ctx, q.Name, index, encoded).Result()
+	payload, _, err := q.store.open(value)
 	if err != nil {
-		return err
+		return "", err
 	}
-	removed, err := q.Redis.LRem(q.ctx, q.Name, 1, encoded).Result()
+	return string(payload), nil
+}
+
+// Range returns the tasks of the queue from index start to stop, inclusive, without removing them.
+// Negative indexes count from the end of the queue, so Range(0, -1) returns every task. If some
+// tasks can't be read, the rest are returned along with a *BatchError.
+func (q *BasicTaskQueue) Range(start, stop int64) ([]string, error) {
+	stored, err := q.store.rangeValues(start, stop)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if removed == 0 {
-		return fmt.Errorf("failed to remove value item %d from queue", index)
+	values := make([]string, len(stored))
+	err = q.store.decodeBatch(stored, false, func(i int, payload []byte) error {
+		values[i] = string(payload)
+		return nil
+	})
+	return values, err
+}
+
+// List returns a Cursor that iterates over the tasks of the queue, pageSize tasks at a time,
+// without removing them.
+func (q *BasicTaskQueue) List(pageSize int64) *Cursor {
+	return q.store.list(pageSize, decodeString)
+}
+
+// RemoveIndex atomically removes the task at an index of the queue and returns it.
+func (q *BasicTaskQueue) RemoveIndex(index int64) (string, error) {
+	value, err := q.store.removeIndex(index)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	payload, _, err := q.store.open(value)
+	if err != nil {
+		return "", err
+	}
+	return string(payload), nil
+}
+
+// Reserve atomically moves the first task from the queue to the consumer's processing list and
+// returns it. The task must be acknowledged with Ack once it has been handled, or returned to the
+// queue with Nack. If the queue is empty, ErrEmpty is returned.
+func (q *BasicTaskQueue) Reserve() (*Task, error) {
+	return q.ReserveContext(q.ctx)
+}
+
+// ReserveContext is the same as Reserve, but stops waiting for the rate limit set with
+// WithRateLimit once ctx is done, in which case the context's error is returned.
+func (q *BasicTaskQueue) ReserveContext(ctx context.Context) (*Task, error) {
+	raw, lease, err := q.store.reserve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return q.task(raw, lease), nil
+}
+
+// BlockingReserve is the same as Reserve, but waits for a task to be added if the queue is empty.
+// If no task arrives before the timeout elapses, ErrTimeout is returned. If ctx is cancelled first,
+// the context's error is returned. A timeout of zero waits indefinitely. Redis waits in steps of one
+// second, so a timeout or cancellation may take up to a second longer to be noticed.
+func (q *BasicTaskQueue) BlockingReserve(ctx context.Context, timeout time.Duration) (*Task, error) {
+	raw, lease, err := q.store.blockingReserve(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return q.task(raw, lease), nil
+}
+
+func (q *BasicTaskQueue) task(raw []byte, lease string) *Task {
+	return q.store.task(raw, lease, decodeString)
+}
+
+// marshal returns the stored values of tasks.
+func (q *BasicTaskQueue) marshal(tasks []any) ([][]byte, error) {
+	bTasks := make([][]byte, 0, len(tasks))
+	for _, task := range tasks {
+		payload, err := basicValue(task)
+		if err != nil {
+			return nil, err
+		}
+		bTask, err := q.store.seal(payload)
+		if err != nil {
+			return nil, err
+		}
+		bTasks = append(bTasks, bTask)
+	}
+	return bTasks, nil
+}
+
+// basicValue returns the value Redis would store for a task added to a BasicTaskQueue, formatted
+// the same way go-redis formats command arguments, so that tasks are stored the same way whether or
+// not they're sealed first. Types go-redis can't send return an error.
+func basicValue(task any) ([]byte, error) {
+	switch v := task.(type) {
+	case nil:
+		return []byte{}, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int8:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case uint:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint8:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint16:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case uint64:
+		return strconv.AppendUint(nil, v, 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'f', -1, 64), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'f', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("1"), nil
+		}
+		return []byte("0"), nil
+	case time.Time:
+		return v.AppendFormat(nil, time.RFC3339Nano), nil
+	case time.Duration:
+		return strconv.AppendInt(nil, v.Nanoseconds(), 10), nil
+	case encoding.BinaryMarshaler:
+		return v.MarshalBinary()
+	case net.IP:
+		return v, nil
+	}
+	return nil, fmt.Errorf("can't store task of type %T (implement encoding.BinaryMarshaler)", task)
 }
@@ -1,6 +1,7 @@
 package taskqueue_test
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -8,6 +9,7 @@ import (
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
 	taskqueue "github.com/stellaraf/go-task-queue"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -37,7 +39,9 @@ func Test_NewBasicTaskQueue(t *testing.T) {
 	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
 	mr := RunT(t)
 	var ctx, addr taskqueue.Option
+	host := Addr
 	if UseMini {
+		host = mr.Addr()
 		ctx = taskqueue.WithContext(mr.Ctx)
 		addr = taskqueue.WithHost(mr.Addr())
 	} else {
@@ -53,9 +57,35 @@ func Test_NewBasicTaskQueue(t *testing.T) {
 
 	t.Run("with uri", func(t *testing.T) {
 		t.Parallel()
-		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("redis://%s", Addr)))
+		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("redis://%s", host)))
 		require.NoError(t, err)
 	})
+
+	t.Run("with uri database", func(t *testing.T) {
+		t.Parallel()
+		queue, err := taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("redis://%s/2?dial_timeout=1s&pool_size=4", host)))
+		require.NoError(t, err)
+		client, ok := queue.Redis.(*redis.Client)
+		require.True(t, ok)
+		assert.Equal(t, 2, client.Options().DB)
+		assert.Equal(t, time.Second, client.Options().DialTimeout)
+		assert.Equal(t, 4, client.Options().PoolSize)
+	})
+
+	t.Run("with invalid uri", func(t *testing.T) {
+		t.Parallel()
+		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("http://%s", host)))
+		assert.ErrorContains(t, err, "invalid Redis URI")
+		_, err = taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("redis://%s?unknown=1", host)))
+		assert.ErrorContains(t, err, "invalid Redis URI")
+	})
+
+	t.Run("with tls uri", func(t *testing.T) {
+		t.Parallel()
+		// The server doesn't accept TLS connections, so connecting fails only if TLS is enabled.
+		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithURI(fmt.Sprintf("rediss://%s", host)), taskqueue.WithTimeout(time.Millisecond*200))
+		assert.Error(t, err)
+	})
 }
 
 func TestBasicTaskQueue_Clear(t *testing.T) {
@@ -265,17 +295,28 @@ func TestBasicTaskQueue_RemoveIndex(t *testing.T) {
 		require.NoError(t, err)
 	})
 	t.Run("remove value by index", func(t *testing.T) {
-		err := queue.RemoveIndex(0)
+		removed, err := queue.RemoveIndex(0)
 		require.NoError(t, err)
+		assert.Equal(t, value, removed)
 	})
 	t.Run("ensure value is removed", func(t *testing.T) {
 		popped := queue.Pop()
 		assert.Nil(t, popped)
 	})
 	t.Run("ensure no removed items errors", func(t *testing.T) {
-		err := queue.RemoveIndex(5)
+		_, err := queue.RemoveIndex(5)
 		require.Error(t, err)
 	})
+	t.Run("only the value at the index is removed", func(t *testing.T) {
+		err := queue.Add("one", "two", "one", "two")
+		require.NoError(t, err)
+		removed, err := queue.RemoveIndex(2)
+		require.NoError(t, err)
+		assert.Equal(t, "one", removed)
+		values, err := queue.PopN(3)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"one", "two", "two"}, values)
+	})
 }
 
 func TestBasicTaskQueue_Has(t *testing.T) {
@@ -307,3 +348,113 @@ func TestBasicTaskQueue_Has(t *testing.T) {
 		require.False(t, has)
 	})
 }
+
+func TestBasicTaskQueue_Values(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	plain, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	defer plain.Clear()
+	enveloped, err := taskqueue.NewBasic(name+"-envelope", ctx, addr, taskqueue.WithEnvelope())
+	require.NoError(t, err)
+	defer enveloped.Clear()
+
+	// Values should be stored the same way whether or not they're sealed before being sent.
+	at := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+	values := []any{true, false, 1e21, float32(1.5), 42, uint8(7), at, time.Second, []byte("bytes")}
+	for _, value := range values {
+		require.NoError(t, plain.Add(value))
+		require.NoError(t, enveloped.Add(value))
+		expected := plain.Pop()
+		require.NotNil(t, expected)
+		actual := enveloped.Pop()
+		require.NotNil(t, actual)
+		assert.Equal(t, *expected, *actual, "%T", value)
+		require.NoError(t, enveloped.Add(value))
+		assert.True(t, enveloped.Has(*expected), "%T", value)
+		require.NoError(t, enveloped.Remove(value))
+		assert.Equal(t, zero, enveloped.Size())
+	}
+	_, err = plain.AddUnique("key", true)
+	require.NoError(t, err)
+	assert.True(t, plain.Has("1"))
+
+	t.Run("unsupported type", func(t *testing.T) {
+		require.Error(t, enveloped.Add(struct{}{}))
+		_, err := enveloped.AddUnique("key", struct{}{})
+		require.Error(t, err)
+	})
+}
+
+func TestBasicTaskQueue_BlockingPop(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	defer queue.Clear()
+	require.NoError(t, err)
+
+	t.Run("existing value", func(t *testing.T) {
+		err := queue.Add("one")
+		require.NoError(t, err)
+		value, err := queue.BlockingPop(context.Background(), time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "one", value)
+	})
+	t.Run("waits for value", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			queue.Add("two")
+		}()
+		value, err := queue.BlockingPop(context.Background(), time.Second*5)
+		require.NoError(t, err)
+		assert.Equal(t, "two", value)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		_, err := queue.BlockingPop(context.Background(), time.Second)
+		assert.ErrorIs(t, err, taskqueue.ErrTimeout)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			cancel()
+		}()
+		_, err := queue.BlockingPop(c, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("unreadable value", func(t *testing.T) {
+		keyring, err := taskqueue.NewKeyring("one", map[string][]byte{"one": bytes.Repeat([]byte{1}, 32)})
+		require.NoError(t, err)
+		other, err := taskqueue.NewKeyring("two", map[string][]byte{"two": bytes.Repeat([]byte{2}, 32)})
+		require.NoError(t, err)
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		encrypted, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(keyring))
+		require.NoError(t, err)
+		defer encrypted.Clear()
+		mismatched, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(other))
+		require.NoError(t, err)
+
+		require.NoError(t, encrypted.Add("secret"))
+		_, err = mismatched.BlockingPop(context.Background(), time.Second)
+		assert.Error(t, err)
+		assert.Equal(t, uint64(1), encrypted.Size())
+	})
+}
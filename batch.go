@@ -0,0 +1,145 @@
+package taskqueue
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// BatchError is returned when some of the tasks in a batch could not be read or unmarshaled. The
+// rest of the batch is returned as usual, and each task that failed is handled the same way as a
+// task that fails when popped on its own.
+type BatchError struct {
+	// Errors maps the index of each task that failed within the batch to its error.
+	Errors map[int]error
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, index := range e.indexes() {
+		messages = append(messages, fmt.Sprintf("task %d: %s", index, e.Errors[index]))
+	}
+	return fmt.Sprintf("%d tasks in batch failed: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of each task that failed, in order.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, index := range e.indexes() {
+		errs = append(errs, e.Errors[index])
+	}
+	return errs
+}
+
+func (e *BatchError) indexes() []int {
+	indexes := make([]int, 0, len(e.Errors))
+	for index := range e.Errors {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// popCountScript removes and returns up to ARGV[1] values from the front of a list, releasing their
+// keys.
+var popCountScript = redis.NewScript(uniqueLua + `
+local values = redis.call("LPOP", KEYS[1], ARGV[1])
+if not values then
+	return {}
+end
+for _, value in ipairs(values) do
+	release(KEYS[2], value)
+end
+return values
+`)
+
+// popN removes and returns up to n values from the front of the queue that haven't expired, using
+// a single command. If a rate limit is set, as many tokens as are available, up to n, are taken,
+// waiting only if none are, and only that many values are popped. Tokens for values that weren't
+// available are returned.
+func (s *store) popN(n int) ([][]byte, error) {
+	if n <= 0 {
+		return [][]byte{}, nil
+	}
+	_, err := s.promote()
+	if err != nil {
+		return nil, err
+	}
+	if s.rate > 0 {
+		n, err = s.takeUpTo(s.ctx, time.Time{}, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	popped, err := popCountScript.Run(s.ctx, s.redis, []string{s.queue, s.unique}, n).StringSlice()
+	if err != nil && err != redis.Nil {
+		if s.rate > 0 {
+			s.refund(n)
+		}
+		return nil, err
+	}
+	values := make([][]byte, 0, len(popped))
+	for _, value := range popped {
+		expired, err := s.expire([]byte(value), "")
+		if err != nil {
+			return nil, err
+		}
+		if !expired {
+			values = append(values, []byte(value))
+		}
+	}
+	if s.rate > 0 && len(values) < n {
+		s.refund(n - len(values))
+	}
+	return values, nil
+}
+
+// decodeBatch reads each stored value with decode, collecting errors by index in a *BatchError. If
+// the values were popped, values that fail are handled the same way as by Pop.
+func (s *store) decodeBatch(stored [][]byte, popped bool, decode func(i int, payload []byte) error) error {
+	batchErr := &BatchError{Errors: map[int]error{}}
+	for i, value := range stored {
+		payload, env, err := s.open(value)
+		if err == nil {
+			err = decode(i, payload)
+		}
+		if err != nil {
+			if popped {
+				err = s.undelivered(value, env, err)
+			}
+			batchErr.Errors[i] = err
+		}
+	}
+	if len(batchErr.Errors) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// decodeSlice decodes stored values into values, which must be a pointer to a slice, the same way
+// as decodeBatch. Values that fail are left as zero values.
+func (s *store) decodeSlice(stored [][]byte, popped bool, values any, decode func(data []byte, value any) error) error {
+	target, err := sliceTarget(values)
+	if err != nil {
+		return err
+	}
+	slice := reflect.MakeSlice(target.Type(), len(stored), len(stored))
+	err = s.decodeBatch(stored, popped, func(i int, payload []byte) error {
+		return decode(payload, slice.Index(i).Addr().Interface())
+	})
+	target.Set(slice)
+	return err
+}
+
+// sliceTarget returns the slice pointed to by values, which must be a pointer to a slice.
+func sliceTarget(values any) (reflect.Value, error) {
+	target := reflect.ValueOf(values)
+	if target.Kind() != reflect.Pointer || target.IsNil() || target.Elem().Kind() != reflect.Slice {
+		return reflect.Value{}, fmt.Errorf("unable to decode tasks into %T, which is not a pointer to a slice", values)
+	}
+	return target.Elem(), nil
+}
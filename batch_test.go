@@ -0,0 +1,107 @@
+package taskqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_PopN(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.Add("one", "two", "three"))
+	values, err := queue.PopN(2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two"}, values)
+	values, err = queue.PopN(2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"three"}, values)
+	values, err = queue.PopN(2)
+	require.NoError(t, err)
+	assert.Empty(t, values)
+}
+
+func TestJSONTaskQueue_PopN(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("pop", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(Value{1}, Value{2}, Value{3}))
+		var values []Value
+		require.NoError(t, queue.PopN(5, &values))
+		assert.Equal(t, []Value{{1}, {2}, {3}}, values)
+		require.NoError(t, queue.PopN(5, &values))
+		assert.Empty(t, values)
+	})
+
+	t.Run("not a slice pointer", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		var values []Value
+		require.Error(t, queue.PopN(5, values))
+	})
+
+	t.Run("per element errors", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithDeadLetterQueue(name+":dlq"))
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(Value{1}, "invalid", Value{3}))
+		var values []Value
+		err = queue.PopN(3, &values)
+		var batchErr *taskqueue.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Len(t, batchErr.Errors, 1)
+		require.Contains(t, batchErr.Errors, 1)
+		var dlErr *taskqueue.DeadLetterError
+		assert.ErrorAs(t, err, &dlErr)
+		assert.Equal(t, []Value{{1}, {}, {3}}, values)
+		assert.Equal(t, zero, queue.Size())
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewTyped[Value](name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add(Value{1}, Value{2}))
+		values, err := queue.PopN(2)
+		require.NoError(t, err)
+		assert.Equal(t, []Value{{1}, {2}}, values)
+	})
+}
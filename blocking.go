@@ -0,0 +1,115 @@
+package taskqueue
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// blockingInterval is the longest a single blocking Redis command is allowed to wait. Long waits
+// are split into intervals of this length so that context cancellation is noticed promptly and
+// scheduled tasks are promoted while waiting. It is also the smallest timeout Redis accepts, so
+// blocking timeouts have a resolution of one second.
+const blockingInterval = time.Second
+
+// blockingDeadline returns the time at which a blocking operation should give up. A timeout of
+// zero results in a zero time, meaning no deadline.
+func blockingDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(timeout)
+}
+
+// blockingExpired determines if a blocking operation has passed its deadline.
+func blockingExpired(deadline time.Time) bool {
+	return !deadline.IsZero() && !time.Now().Before(deadline)
+}
+
+// blockingPop removes and returns the first value of the queue, waiting until a value is
+// available, the timeout elapses, or ctx is done.
+func (s *store) blockingPop(ctx context.Context, timeout time.Duration) ([]byte, error) {
+	return s.block(ctx, timeout, func() ([]byte, error) {
+		// Moving the first value to the front of the same list waits for a value without taking it,
+		// so that it can be popped by a script that also releases its key. If another consumer pops
+		// it first, the script returns redis.Nil, and the attempt is retried.
+		err := s.redis.BLMove(ctx, s.queue, s.queue, "LEFT", "LEFT", blockingInterval).Err()
+		if err != nil {
+			return nil, err
+		}
+		popped, err := s.popOne(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return s.unexpired(popped, "")
+	})
+}
+
+// blockingMove atomically moves the first value of the queue to the end of the consumer's
+// processing list, waiting until a value is available, the timeout elapses, or ctx is done. The
+// value is returned along with the lease to record for it.
+func (s *store) blockingMove(ctx context.Context, timeout time.Duration) ([]byte, string, error) {
+	var lease string
+	moved, err := s.block(ctx, timeout, func() ([]byte, error) {
+		prefix, err := s.leasePrefix()
+		if err != nil {
+			return nil, err
+		}
+		moved, err := s.redis.BLMove(ctx, s.queue, s.processing, "LEFT", "RIGHT", blockingInterval).Bytes()
+		if err != nil {
+			return nil, err
+		}
+		lease = prefix + string(moved)
+		return s.unexpired(moved, lease)
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return moved, lease, nil
+}
+
+// unexpired returns a value taken by a blocking command, or redis.Nil if it has expired so that the
+// command is retried. If the value was reserved, lease is the lease to record for it.
+func (s *store) unexpired(value []byte, lease string) ([]byte, error) {
+	expired, err := s.expire(value, lease)
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, redis.Nil
+	}
+	return value, nil
+}
+
+// block calls attempt, a blocking command that waits for up to blockingInterval, until it returns a
+// value, the timeout elapses, or ctx is done. A token is taken from the queue's rate limiter before
+// the first attempt.
+func (s *store) block(ctx context.Context, timeout time.Duration, attempt func() ([]byte, error)) ([]byte, error) {
+	deadline := blockingDeadline(timeout)
+	return s.limited(ctx, deadline, func() ([]byte, error) {
+		for {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			if blockingExpired(deadline) {
+				return nil, ErrTimeout
+			}
+			_, err := s.promote()
+			if err != nil {
+				return nil, err
+			}
+			value, err := attempt()
+			if err == redis.Nil {
+				continue
+			}
+			if err != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return nil, ctxErr
+				}
+				return nil, err
+			}
+			return value, nil
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package taskqueue
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisClient creates a Redis client from options and ensures Redis is reachable. If a client
+// was provided with WithClient, it is used as-is.
+func newRedisClient(options *Options) (redis.UniversalClient, error) {
+	var redisClient redis.UniversalClient
+	switch {
+	case options.Client != nil:
+		redisClient = options.Client
+	case len(options.ClusterAddrs) > 0:
+		redisClient = redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        options.ClusterAddrs,
+			Username:     options.Username,
+			Password:     options.Password,
+			TLSConfig:    options.TLSConfig,
+			ReadTimeout:  options.Timeout,
+			WriteTimeout: options.Timeout,
+		})
+	case options.SentinelMaster != "":
+		redisClient = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    options.SentinelMaster,
+			SentinelAddrs: options.SentinelAddrs,
+			Username:      options.Username,
+			Password:      options.Password,
+			TLSConfig:     options.TLSConfig,
+			ReadTimeout:   options.Timeout,
+			WriteTimeout:  options.Timeout,
+		})
+	case options.uri != nil:
+		redisClient = redis.NewClient(uriOptions(options))
+	default:
+		redisClient = redis.NewClient(&redis.Options{
+			Addr:         options.Host,
+			Username:     options.Username,
+			Password:     options.Password,
+			TLSConfig:    options.TLSConfig,
+			ReadTimeout:  options.Timeout,
+			WriteTimeout: options.Timeout,
+			Network:      "tcp",
+		})
+	}
+	_, err := redisClient.Ping(options.Context).Result()
+	if err != nil {
+		return nil, err
+	}
+	return redisClient, nil
+}
+
+// uriOptions returns the client options parsed from the URI, filling in any settings the URI
+// doesn't specify from options.
+func uriOptions(options *Options) *redis.Options {
+	redisOptions := *options.uri
+	redisOptions.TLSConfig = options.TLSConfig
+	if redisOptions.ReadTimeout == 0 {
+		redisOptions.ReadTimeout = options.Timeout
+	}
+	if redisOptions.WriteTimeout == 0 {
+		redisOptions.WriteTimeout = options.Timeout
+	}
+	return &redisOptions
+}
+
+// validTopology ensures at most one of a client, cluster addresses, or a Sentinel master is set.
+func validTopology(options *Options) error {
+	set := 0
+	if options.Client != nil {
+		set++
+	}
+	if len(options.ClusterAddrs) > 0 {
+		set++
+	}
+	if options.SentinelMaster != "" {
+		set++
+		if len(options.SentinelAddrs) == 0 {
+			return fmt.Errorf("sentinel master '%s' requires at least one sentinel address", options.SentinelMaster)
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("only one of WithClient, WithClusterAddrs, or WithSentinel may be used")
+	}
+	return nil
+}
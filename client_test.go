@@ -0,0 +1,70 @@
+package taskqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithClient(t *testing.T) {
+	mr := RunT(t)
+	var ctx taskqueue.Option
+	var client *redis.Client
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		client = redis.NewClient(&redis.Options{Addr: Addr})
+	}
+	defer client.Close()
+
+	t.Run("shared", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		basic, err := taskqueue.NewBasic(name, ctx, taskqueue.WithClient(client))
+		require.NoError(t, err)
+		defer basic.Clear()
+		json, err := taskqueue.NewJSON(name, ctx, taskqueue.WithClient(client))
+		require.NoError(t, err)
+		assert.Same(t, client, basic.Redis)
+		assert.Same(t, client, json.Redis)
+
+		require.NoError(t, basic.Add(`"value"`))
+		var value string
+		require.NoError(t, json.Pop(&value))
+		assert.Equal(t, "value", value)
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		if !UseMini {
+			t.Skip("requires a Redis Cluster")
+		}
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, taskqueue.WithClusterAddrs(mr.Addr()))
+		require.NoError(t, err)
+		defer queue.Clear()
+		_, ok := queue.Redis.(*redis.ClusterClient)
+		assert.True(t, ok)
+		require.NoError(t, queue.Add("value"))
+		value := queue.Pop()
+		require.NotNil(t, value)
+		assert.Equal(t, "value", *value)
+	})
+
+	t.Run("conflicting options", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithClient(client), taskqueue.WithClusterAddrs("localhost:7000"))
+		assert.Error(t, err)
+	})
+
+	t.Run("sentinel without addresses", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		_, err := taskqueue.NewBasic(name, ctx, taskqueue.WithSentinel("mymaster"))
+		assert.Error(t, err)
+	})
+}
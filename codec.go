@@ -0,0 +1,129 @@
+package taskqueue
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec marshals and unmarshals the tasks of a JSONTaskQueue, TypedQueue, or PriorityTaskQueue.
+// Has and Remove find tasks by comparing their marshaled values, so a Codec must marshal equal
+// values to the same bytes every time.
+type Codec interface {
+	// Marshal returns the encoding of v.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes data and stores the result in the value pointed to by v.
+	Unmarshal(data []byte, v any) error
+	// ContentType returns the media type of the encoding.
+	ContentType() string
+}
+
+// JSONCodec encodes tasks as JSON using encoding/json. It is the default Codec.
+type JSONCodec struct{}
+
+// Marshal returns the JSON encoding of v.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// ContentType returns the media type of JSON.
+func (JSONCodec) ContentType() string {
+	return "application/json"
+}
+
+// GobCodec encodes tasks using encoding/gob. Each task is encoded as a separate stream, so types
+// don't need to be registered unless they're stored in interface values. Gob encodes maps in a
+// random order, so Has and Remove can't find tasks that contain maps.
+type GobCodec struct{}
+
+// Marshal returns the gob encoding of v.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// ContentType returns the media type of gob.
+func (GobCodec) ContentType() string {
+	return "application/x-gob"
+}
+
+// MsgpackCodec encodes tasks as MessagePack. Struct fields are named by their json tags, so types
+// used with JSONCodec can be used without change. The keys of maps of type map[string]string,
+// map[string]bool, and map[string]any are sorted so that equal values are always encoded the same
+// way; other maps are encoded in a random order, so Has and Remove can't find tasks that contain
+// them.
+type MsgpackCodec struct{}
+
+// Marshal returns the MessagePack encoding of v.
+func (MsgpackCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	enc.SetSortMapKeys(true)
+	err := enc.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack data into v.
+func (MsgpackCodec) Unmarshal(data []byte, v any) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// ContentType returns the media type of MessagePack.
+func (MsgpackCodec) ContentType() string {
+	return "application/msgpack"
+}
+
+// StringCodec stores tasks without encoding them, the same way as a BasicTaskQueue, so that string
+// payloads can be stored as is. Tasks may be strings, byte slices, numbers, booleans, times, or
+// types that implement encoding.BinaryMarshaler, and are unmarshaled into a *string, a *[]byte, or
+// a type that implements encoding.BinaryUnmarshaler.
+type StringCodec struct{}
+
+// Marshal returns the stored form of v.
+func (StringCodec) Marshal(v any) ([]byte, error) {
+	return basicValue(v)
+}
+
+// Unmarshal stores data in v.
+func (StringCodec) Unmarshal(data []byte, v any) error {
+	switch v := v.(type) {
+	case *string:
+		*v = string(data)
+	case *[]byte:
+		*v = append([]byte(nil), data...)
+	case encoding.BinaryUnmarshaler:
+		return v.UnmarshalBinary(data)
+	default:
+		return fmt.Errorf("can't unmarshal task into %T", v)
+	}
+	return nil
+}
+
+// ContentType returns the media type of plain text.
+func (StringCodec) ContentType() string {
+	return "text/plain"
+}
@@ -0,0 +1,100 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		String string `json:"string"`
+		Int    int    `json:"int"`
+	}
+
+	codecs := []taskqueue.Codec{taskqueue.JSONCodec{}, taskqueue.GobCodec{}, taskqueue.MsgpackCodec{}}
+	for _, codec := range codecs {
+		codec := codec
+		t.Run(codec.ContentType(), func(t *testing.T) {
+			name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+			queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithCodec(codec))
+			require.NoError(t, err)
+			defer queue.Clear()
+
+			err = queue.Add(Value{"one", 1}, Value{"two", 2}, Value{"three", 3})
+			require.NoError(t, err)
+			assert.True(t, queue.Has(Value{"two", 2}))
+			assert.False(t, queue.Has(Value{"four", 4}))
+
+			var got Value
+			err = queue.Get(2, &got)
+			require.NoError(t, err)
+			assert.Equal(t, Value{"three", 3}, got)
+
+			err = queue.Remove(Value{"two", 2})
+			require.NoError(t, err)
+			assert.Equal(t, uint64(2), queue.Size())
+
+			var popped *Value
+			err = queue.Pop(&popped)
+			require.NoError(t, err)
+			assert.Equal(t, Value{"one", 1}, *popped)
+		})
+	}
+
+	t.Run("msgpack map keys are sorted", func(t *testing.T) {
+		codec := taskqueue.MsgpackCodec{}
+		value := map[string]any{"a": 1, "b": "two", "c": 3.0, "d": true, "e": nil}
+		first, err := codec.Marshal(value)
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			next, err := codec.Marshal(value)
+			require.NoError(t, err)
+			assert.Equal(t, first, next)
+		}
+	})
+
+	t.Run("string", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithCodec(taskqueue.StringCodec{}))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add("one", []byte("two"), 3))
+		assert.Equal(t, []string{"one", "two", "3"}, queue.Redis.LRange(context.Background(), name, 0, -1).Val())
+		assert.True(t, queue.Has("two"))
+		var value string
+		require.NoError(t, queue.Pop(&value))
+		assert.Equal(t, "one", value)
+		var b []byte
+		require.NoError(t, queue.Pop(&b))
+		assert.Equal(t, []byte("two"), b)
+		var v Value
+		require.Error(t, taskqueue.StringCodec{}.Unmarshal([]byte("3"), &v))
+		require.Error(t, queue.Add(Value{"four", 4}))
+	})
+	t.Run("typed queue", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewTyped[Value](name, ctx, addr, taskqueue.WithCodec(taskqueue.MsgpackCodec{}))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add(Value{"one", 1}))
+		value, err := queue.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, Value{"one", 1}, value)
+	})
+}
@@ -0,0 +1,133 @@
+package taskqueue
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+)
+
+// Compression is an algorithm used to compress stored task values.
+type Compression string
+
+const (
+	// CompressionGzip compresses task values with gzip.
+	CompressionGzip Compression = "gzip"
+	// CompressionZstd compresses task values with Zstandard.
+	CompressionZstd Compression = "zstd"
+)
+
+// compressions are the supported compression algorithms.
+var compressions = []Compression{CompressionGzip, CompressionZstd}
+
+var (
+	zstdOnce    sync.Once
+	zstdEncoder *zstd.Encoder
+	zstdDecoder *zstd.Decoder
+	zstdErr     error
+)
+
+// header returns the beginning of every value compressed with the algorithm, used to tell
+// compressed values apart from values stored without compression.
+func (c Compression) header() []byte {
+	return []byte("\x00taskqueue:" + string(c) + "\x00")
+}
+
+// valid determines if the algorithm is supported.
+func (c Compression) valid() bool {
+	for _, compression := range compressions {
+		if c == compression {
+			return true
+		}
+	}
+	return false
+}
+
+// compress returns the compressed form of value, beginning with the algorithm's header.
+func (c Compression) compress(value []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(c.header())
+	switch c {
+	case CompressionGzip:
+		w := gzip.NewWriter(buf)
+		_, err := w.Write(value)
+		if err != nil {
+			return nil, err
+		}
+		err = w.Close()
+		if err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case CompressionZstd:
+		enc, _, err := zstdCoders()
+		if err != nil {
+			return nil, err
+		}
+		return enc.EncodeAll(value, buf.Bytes()), nil
+	}
+	return nil, fmt.Errorf("unsupported compression algorithm '%s'", c)
+}
+
+// decompress returns the original form of a value compressed with the algorithm, without its
+// header.
+func (c Compression) decompress(value []byte) ([]byte, error) {
+	switch c {
+	case CompressionGzip:
+		r, err := gzip.NewReader(bytes.NewReader(value))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	case CompressionZstd:
+		_, dec, err := zstdCoders()
+		if err != nil {
+			return nil, err
+		}
+		return dec.DecodeAll(value, nil)
+	}
+	return nil, fmt.Errorf("unsupported compression algorithm '%s'", c)
+}
+
+// zstdCoders returns the Zstandard encoder and decoder shared by every queue, creating them the
+// first time they're needed.
+func zstdCoders() (*zstd.Encoder, *zstd.Decoder, error) {
+	zstdOnce.Do(func() {
+		zstdEncoder, zstdErr = zstd.NewWriter(nil)
+		if zstdErr != nil {
+			return
+		}
+		zstdDecoder, zstdErr = zstd.NewReader(nil)
+	})
+	return zstdEncoder, zstdDecoder, zstdErr
+}
+
+// compress returns the compressed form of a stored value if compression is enabled and the value
+// is larger than the threshold.
+func (s *store) compress(value []byte) ([]byte, error) {
+	if s.compression == "" || len(value) <= s.threshold {
+		return value, nil
+	}
+	return s.compression.compress(value)
+}
+
+// decompress returns the original form of a stored value. Values compressed with any supported
+// algorithm are decompressed, regardless of the queue's own compression, and values stored without
+// compression are returned unchanged.
+func (s *store) decompress(value []byte) ([]byte, error) {
+	for _, compression := range compressions {
+		header := compression.header()
+		if bytes.HasPrefix(value, header) {
+			decompressed, err := compression.decompress(value[len(header):])
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to decompress %s task", compression)
+			}
+			return decompressed, nil
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,144 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONTaskQueue_Compression(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		String string `json:"string"`
+	}
+
+	small := Value{"small"}
+	large := Value{strings.Repeat("large", 200)}
+
+	for _, algorithm := range []taskqueue.Compression{taskqueue.CompressionGzip, taskqueue.CompressionZstd} {
+		algorithm := algorithm
+		t.Run(string(algorithm), func(t *testing.T) {
+			name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+			queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithCompression(algorithm, 100))
+			require.NoError(t, err)
+			defer queue.Clear()
+
+			err = queue.Add(small, large)
+			require.NoError(t, err)
+
+			stored, err := queue.Redis.LRange(context.Background(), name, 0, -1).Result()
+			require.NoError(t, err)
+			assert.Equal(t, `{"string":"small"}`, stored[0])
+			assert.True(t, strings.HasPrefix(stored[1], "\x00taskqueue:"+string(algorithm)))
+			assert.Less(t, len(stored[1]), len(large.String))
+
+			assert.True(t, queue.Has(large))
+			var got Value
+			err = queue.Get(1, &got)
+			require.NoError(t, err)
+			assert.Equal(t, large, got)
+
+			var popped Value
+			require.NoError(t, queue.Pop(&popped))
+			assert.Equal(t, small, popped)
+			require.NoError(t, queue.Pop(&popped))
+			assert.Equal(t, large, popped)
+
+			err = queue.Add(large)
+			require.NoError(t, err)
+			err = queue.Remove(large)
+			require.NoError(t, err)
+			assert.Equal(t, zero, queue.Size())
+		})
+	}
+
+	t.Run("reads uncompressed tasks", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		plain, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		defer plain.Clear()
+		compressed, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithCompression(taskqueue.CompressionZstd, 0))
+		require.NoError(t, err)
+
+		require.NoError(t, plain.Add(large))
+		require.NoError(t, compressed.Add(large))
+
+		var popped Value
+		require.NoError(t, compressed.Pop(&popped))
+		assert.Equal(t, large, popped)
+		bytes, err := plain.PopBytes()
+		require.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf(`{"string":"%s"}`, large.String), string(bytes))
+	})
+
+	t.Run("with envelope", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(
+			name, ctx, addr,
+			taskqueue.WithEnvelope(),
+			taskqueue.WithCompression(taskqueue.CompressionGzip, 0),
+		)
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add(large))
+		assert.True(t, queue.Has(large))
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		require.NotNil(t, task.Envelope())
+		var popped Value
+		require.NoError(t, task.Decode(&popped))
+		assert.Equal(t, large, popped)
+		require.NoError(t, task.Nack())
+		require.NoError(t, queue.Pop(&popped))
+		assert.Equal(t, large, popped)
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		_, err := taskqueue.NewJSON("name", ctx, addr, taskqueue.WithCompression("lz4", 0))
+		require.Error(t, err)
+	})
+}
+
+func TestBasicTaskQueue_Compression(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithCompression(taskqueue.CompressionGzip, 10))
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	value := strings.Repeat("value", 10)
+	require.NoError(t, queue.Add(value, "short"))
+	assert.True(t, queue.Has(value))
+	got, err := queue.Get(0)
+	require.NoError(t, err)
+	assert.Equal(t, value, got)
+	require.NoError(t, queue.Remove(value))
+	assert.Equal(t, uint64(1), queue.Size())
+	popped := queue.Pop()
+	require.NotNil(t, popped)
+	assert.Equal(t, "short", *popped)
+}
@@ -0,0 +1,58 @@
+package taskqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DeadLetter is a task that was moved to a dead-letter queue because it could not be handled.
+// Dead letters are stored as JSON, so a dead-letter queue can be read with a JSONTaskQueue.
+type DeadLetter struct {
+	// Payload is the task value exactly as it was stored in the queue.
+	Payload []byte `json:"payload"`
+	// Error is the text of the error that caused the task to be dead-lettered.
+	Error string `json:"error"`
+	// Timestamp is the time at which the task was dead-lettered.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeadLetterError is returned when a task could not be handled and was moved to a dead-letter
+// queue instead of being returned.
+type DeadLetterError struct {
+	// Queue is the name of the dead-letter queue the task was moved to.
+	Queue string
+	// Err is the error that caused the task to be dead-lettered.
+	Err error
+}
+
+func (e *DeadLetterError) Error() string {
+	return fmt.Sprintf("task moved to dead-letter queue '%s': %s", e.Queue, e.Err)
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
+}
+
+// newDeadLetter returns the stored form of a DeadLetter for a task that failed with cause.
+func newDeadLetter(payload []byte, cause error) ([]byte, error) {
+	letter := DeadLetter{Payload: payload, Error: cause.Error(), Timestamp: time.Now()}
+	return json.Marshal(letter)
+}
+
+// deadLetter adds a task to the end of a dead-letter queue along with the error that caused it to
+// be dead-lettered. The returned error is a *DeadLetterError unless adding the task failed.
+func deadLetter(ctx context.Context, client redis.UniversalClient, queue string, payload []byte, cause error) error {
+	letter, err := newDeadLetter(payload, cause)
+	if err != nil {
+		return err
+	}
+	err = client.RPush(ctx, queue, letter).Err()
+	if err != nil {
+		return err
+	}
+	return &DeadLetterError{Queue: queue, Err: cause}
+}
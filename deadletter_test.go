@@ -0,0 +1,62 @@
+package taskqueue_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONTaskQueue_DeadLetter(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	dlqName := name + "--dead"
+	queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithDeadLetterQueue(dlqName))
+	require.NoError(t, err)
+	dlq, err := taskqueue.NewJSON(dlqName, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+		dlq.Clear()
+	})
+
+	in := RetryValue{"value"}
+	inB, err := json.Marshal(in)
+	require.NoError(t, err)
+
+	t.Run("pop returns dead letter error", func(t *testing.T) {
+		err := queue.Add(in)
+		require.NoError(t, err)
+		var popped *RetryValue
+		err = queue.Pop(&popped)
+		var dlErr *taskqueue.DeadLetterError
+		require.ErrorAs(t, err, &dlErr)
+		assert.Equal(t, dlqName, dlErr.Queue)
+		assert.EqualError(t, dlErr.Err, "wrong")
+	})
+	t.Run("task is not re-added", func(t *testing.T) {
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, uint64(1), dlq.Size())
+	})
+	t.Run("dead letter contents", func(t *testing.T) {
+		var letter *taskqueue.DeadLetter
+		err := dlq.Pop(&letter)
+		require.NoError(t, err)
+		assert.Equal(t, inB, letter.Payload)
+		assert.Equal(t, "wrong", letter.Error)
+		assert.WithinDuration(t, time.Now(), letter.Timestamp, time.Minute)
+	})
+}
@@ -0,0 +1,137 @@
+package taskqueue
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/pkg/errors"
+)
+
+// encryptionHeader is the beginning of every encrypted value, used to tell encrypted values apart
+// from values stored without encryption. It is followed by the length of the key ID, the key ID,
+// the nonce, and the sealed value.
+var encryptionHeader = []byte("\x00taskqueue:aes-gcm\x00")
+
+// Keyring holds the AES keys used to encrypt and decrypt task values, identified by key ID. Values
+// are always encrypted with the primary key, and can be decrypted with any key in the keyring, so
+// keys can be rotated by adding a new primary key while keeping the old one until every value it
+// encrypted has been consumed.
+type Keyring struct {
+	primary string
+	keys    map[string]*keyringKey
+}
+
+// keyringKey is a key in a Keyring.
+type keyringKey struct {
+	aead  cipher.AEAD
+	nonce []byte
+}
+
+// NewKeyring creates a new Keyring from AES-128, AES-192, or AES-256 keys, mapped by key ID. The
+// primary key ID must be one of the keys.
+func NewKeyring(primary string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primary]; !ok {
+		return nil, fmt.Errorf("primary key '%s' is not in the keyring", primary)
+	}
+	keyring := &Keyring{primary: primary, keys: make(map[string]*keyringKey, len(keys))}
+	for id, key := range keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("key ID '%s' is longer than 255 bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key '%s'", id)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid key '%s'", id)
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte("taskqueue nonce"))
+		keyring.keys[id] = &keyringKey{aead: aead, nonce: mac.Sum(nil)}
+	}
+	return keyring, nil
+}
+
+// encrypt returns the encrypted form of value, sealed with the primary key.
+func (k *Keyring) encrypt(value []byte) []byte {
+	return k.encryptWith(k.primary, value)
+}
+
+// encryptAll returns the encrypted forms of value sealed with each key in the keyring, so that
+// values encrypted before the primary key was rotated can be found as well.
+func (k *Keyring) encryptAll(value []byte) [][]byte {
+	encrypted := make([][]byte, 0, len(k.keys))
+	for id := range k.keys {
+		encrypted = append(encrypted, k.encryptWith(id, value))
+	}
+	return encrypted
+}
+
+// encryptWith returns the encrypted form of value, sealed with the key with the given ID. The
+// nonce is derived from the value, so equal values are always encrypted the same way by the same
+// key, which allows Has and Remove to find encrypted tasks. This reveals which stored values are
+// equal, but nothing else about them.
+func (k *Keyring) encryptWith(id string, value []byte) []byte {
+	key := k.keys[id]
+	mac := hmac.New(sha256.New, key.nonce)
+	mac.Write(value)
+	nonce := mac.Sum(nil)[:key.aead.NonceSize()]
+
+	buf := bytes.NewBuffer(nil)
+	buf.Write(encryptionHeader)
+	buf.WriteByte(byte(len(id)))
+	buf.WriteString(id)
+	buf.Write(nonce)
+	return key.aead.Seal(buf.Bytes(), nonce, value, []byte(id))
+}
+
+// decrypt returns the original form of an encrypted value, without its header.
+func (k *Keyring) decrypt(value []byte) ([]byte, error) {
+	if len(value) < 1 {
+		return nil, fmt.Errorf("encrypted task is malformed")
+	}
+	n := 1 + int(value[0])
+	if len(value) < n {
+		return nil, fmt.Errorf("encrypted task is malformed")
+	}
+	id := string(value[1:n])
+	value = value[n:]
+	key, ok := k.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("key '%s' is not in the keyring", id)
+	}
+	if len(value) < key.aead.NonceSize() {
+		return nil, fmt.Errorf("encrypted task is malformed")
+	}
+	nonce, sealed := value[:key.aead.NonceSize()], value[key.aead.NonceSize():]
+	return key.aead.Open(nil, nonce, sealed, []byte(id))
+}
+
+// encrypt returns the encrypted form of a stored value if encryption is enabled.
+func (s *store) encrypt(value []byte) []byte {
+	if s.keyring == nil {
+		return value
+	}
+	return s.keyring.encrypt(value)
+}
+
+// decrypt returns the original form of a stored value. Values stored without encryption are
+// returned unchanged.
+func (s *store) decrypt(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, encryptionHeader) {
+		return value, nil
+	}
+	if s.keyring == nil {
+		return nil, fmt.Errorf("task is encrypted, but no keyring is set")
+	}
+	decrypted, err := s.keyring.decrypt(value[len(encryptionHeader):])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decrypt task")
+	}
+	return decrypted, nil
+}
@@ -0,0 +1,185 @@
+package taskqueue_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewKeyring(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		_, err := taskqueue.NewKeyring("one", map[string][]byte{"one": bytes.Repeat([]byte{1}, 32)})
+		require.NoError(t, err)
+	})
+	t.Run("missing primary key", func(t *testing.T) {
+		_, err := taskqueue.NewKeyring("two", map[string][]byte{"one": bytes.Repeat([]byte{1}, 32)})
+		require.Error(t, err)
+	})
+	t.Run("invalid key size", func(t *testing.T) {
+		_, err := taskqueue.NewKeyring("one", map[string][]byte{"one": bytes.Repeat([]byte{1}, 10)})
+		require.Error(t, err)
+	})
+}
+
+func TestBasicTaskQueue_Encryption(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	oldKey := bytes.Repeat([]byte{1}, 32)
+	newKey := bytes.Repeat([]byte{2}, 32)
+	oldKeyring, err := taskqueue.NewKeyring("old", map[string][]byte{"old": oldKey})
+	require.NoError(t, err)
+	newKeyring, err := taskqueue.NewKeyring("new", map[string][]byte{"old": oldKey, "new": newKey})
+	require.NoError(t, err)
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(oldKeyring))
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	t.Run("stored encrypted", func(t *testing.T) {
+		require.NoError(t, queue.Add("secret1", "secret2"))
+		stored, err := queue.Redis.LRange(context.Background(), name, 0, -1).Result()
+		require.NoError(t, err)
+		for _, value := range stored {
+			assert.False(t, strings.Contains(value, "secret"))
+		}
+	})
+	t.Run("has and get", func(t *testing.T) {
+		assert.True(t, queue.Has("secret2"))
+		assert.False(t, queue.Has("secret3"))
+		value, err := queue.Get(1)
+		require.NoError(t, err)
+		assert.Equal(t, "secret2", value)
+	})
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, queue.Remove("secret2"))
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+	t.Run("rotated key", func(t *testing.T) {
+		rotated, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(newKeyring))
+		require.NoError(t, err)
+		require.NoError(t, rotated.Add("secret3"))
+		value := rotated.Pop()
+		require.NotNil(t, value)
+		assert.Equal(t, "secret1", *value)
+		value = rotated.Pop()
+		require.NotNil(t, value)
+		assert.Equal(t, "secret3", *value)
+	})
+	t.Run("has and remove after rotation", func(t *testing.T) {
+		require.NoError(t, queue.Add("secret5"))
+		rotated, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(newKeyring))
+		require.NoError(t, err)
+		assert.True(t, rotated.Has("secret5"))
+		require.NoError(t, rotated.Remove("secret5"))
+		assert.False(t, rotated.Has("secret5"))
+		assert.Equal(t, zero, queue.Size())
+	})
+	t.Run("unknown key", func(t *testing.T) {
+		rotated, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(newKeyring))
+		require.NoError(t, err)
+		require.NoError(t, rotated.Add("secret4"))
+		_, err = queue.Get(0)
+		require.Error(t, err)
+	})
+	t.Run("long key ID", func(t *testing.T) {
+		id := strings.Repeat("k", 255)
+		keyring, err := taskqueue.NewKeyring(id, map[string][]byte{id: newKey})
+		require.NoError(t, err)
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(keyring))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add("secret"))
+		value, err := queue.Get(0)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", value)
+	})
+	t.Run("malformed", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEncryption(oldKeyring))
+		require.NoError(t, err)
+		defer queue.Clear()
+		header := "\x00taskqueue:aes-gcm\x00"
+		malformed := []string{
+			header,
+			header + "\xff",
+			header + "\xffold",
+			header + "\x03old" + "short",
+			header + "\x03old" + strings.Repeat("n", 12) + "not a ciphertext",
+		}
+		for i, value := range malformed {
+			require.NoError(t, queue.Redis.RPush(context.Background(), name, value).Err())
+			_, err := queue.Get(int64(i))
+			assert.Error(t, err)
+		}
+	})
+	t.Run("no keyring", func(t *testing.T) {
+		plain, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithNoRetry())
+		require.NoError(t, err)
+		_, err = plain.Get(0)
+		require.Error(t, err)
+		_, err = plain.BlockingPop(context.Background(), time.Second)
+		require.Error(t, err)
+	})
+}
+
+func TestJSONTaskQueue_Encryption(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		Password string `json:"password"`
+	}
+
+	keyring, err := taskqueue.NewKeyring("key", map[string][]byte{"key": bytes.Repeat([]byte{1}, 16)})
+	require.NoError(t, err)
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(
+		name, ctx, addr,
+		taskqueue.WithEncryption(keyring),
+		taskqueue.WithCompression(taskqueue.CompressionGzip, 0),
+		taskqueue.WithEnvelope(),
+	)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.Add(Value{"hunter2"}))
+	stored, err := queue.Redis.LIndex(context.Background(), name, 0).Result()
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(stored, "hunter2"))
+	assert.True(t, queue.Has(Value{"hunter2"}))
+
+	task, err := queue.Reserve()
+	require.NoError(t, err)
+	require.NotNil(t, task.Envelope())
+	var value Value
+	require.NoError(t, task.Decode(&value))
+	assert.Equal(t, "hunter2", value.Password)
+	require.NoError(t, task.Nack())
+
+	require.NoError(t, queue.Pop(&value))
+	assert.Equal(t, "hunter2", value.Password)
+}
@@ -0,0 +1,172 @@
+package taskqueue
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// envelopePrefix is the beginning of every stored envelope, used to tell envelopes apart from
+// task values stored without one.
+var envelopePrefix = []byte(`{"taskqueue_envelope":1,`)
+
+// Envelope wraps a task value with metadata used to track its delivery. Envelopes are only stored
+// when enabled with WithEnvelope or WithMaxAttempts.
+type Envelope struct {
+	// ID uniquely identifies the task.
+	ID string `json:"id"`
+	// EnqueuedAt is the time at which the task was added to the queue.
+	EnqueuedAt time.Time `json:"enqueued_at"`
+	// Attempts is the number of times the task has failed.
+	Attempts int `json:"attempts"`
+	// LastError is the text of the error from the most recent failed attempt.
+	LastError string `json:"last_error,omitempty"`
+	// Key is the key the task was added with by AddUnique, if any.
+	Key string `json:"key,omitempty"`
+	// ExpiresAt is the time after which the task is discarded instead of being handled, if it was
+	// added with AddWithTTL.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// Payload is the task value.
+	Payload []byte `json:"payload"`
+}
+
+// storedEnvelope is the stored form of an Envelope, which begins with envelopePrefix.
+type storedEnvelope struct {
+	Version int `json:"taskqueue_envelope"`
+	*Envelope
+}
+
+// newEnvelope wraps a task value in a new Envelope.
+func newEnvelope(payload []byte) (*Envelope, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	env := &Envelope{
+		ID:         hex.EncodeToString(id),
+		EnqueuedAt: time.Now(),
+		Payload:    payload,
+	}
+	return env, nil
+}
+
+// sealEnvelope returns the stored form of an Envelope.
+func sealEnvelope(env *Envelope) ([]byte, error) {
+	return json.Marshal(storedEnvelope{Version: 1, Envelope: env})
+}
+
+// openEnvelope returns the Envelope a stored value is wrapped in, or nil if the value was stored
+// without one.
+func openEnvelope(raw []byte) *Envelope {
+	if !bytes.HasPrefix(raw, envelopePrefix) {
+		return nil
+	}
+	var stored storedEnvelope
+	err := json.Unmarshal(raw, &stored)
+	if err != nil || stored.Envelope == nil {
+		return nil
+	}
+	return stored.Envelope
+}
+
+// seal returns the stored form of a task value, which is wrapped in an envelope, compressed, and
+// encrypted if enabled.
+func (s *store) seal(payload []byte) ([]byte, error) {
+	if !s.envelope {
+		return s.pack(payload)
+	}
+	env, err := newEnvelope(payload)
+	if err != nil {
+		return nil, err
+	}
+	return s.reseal(env)
+}
+
+// reseal returns the stored form of an existing Envelope.
+func (s *store) reseal(env *Envelope) ([]byte, error) {
+	value, err := sealEnvelope(env)
+	if err != nil {
+		return nil, err
+	}
+	return s.pack(value)
+}
+
+// open returns the task value of a stored value, along with its envelope if it has one.
+func (s *store) open(raw []byte) ([]byte, *Envelope, error) {
+	value, err := s.unpack(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	env := openEnvelope(value)
+	if env == nil {
+		return value, nil, nil
+	}
+	return env.Payload, env, nil
+}
+
+// exhausted records a failed attempt in a task's envelope and determines if the task has reached
+// its maximum number of attempts. Tasks without an envelope can't track attempts and are never
+// exhausted.
+func (s *store) exhausted(env *Envelope, cause error) bool {
+	if env == nil {
+		return false
+	}
+	env.Attempts++
+	if cause != nil {
+		env.LastError = cause.Error()
+	}
+	return s.maxAttempts > 0 && env.Attempts >= s.maxAttempts
+}
+
+// match returns every stored value in the queue whose task value is equal to payload. Stored
+// envelopes differ from their task values, so they're read and compared. Unless envelopes are
+// enabled, only stored values that may be envelopes are read.
+func (s *store) match(payload []byte) ([]string, error) {
+	var values []string
+	var err error
+	if s.envelope {
+		values, err = s.redis.LRange(s.ctx, s.queue, 0, -1).Result()
+	} else {
+		values, err = wrappedScript.Run(s.ctx, s.redis, []string{s.queue}, wrappedPrefixes()...).StringSlice()
+	}
+	if err != nil {
+		return nil, err
+	}
+	matches := []string{}
+	for _, value := range values {
+		opened, _, err := s.open([]byte(value))
+		if err == nil && bytes.Equal(opened, payload) {
+			matches = append(matches, value)
+		}
+	}
+	return matches, nil
+}
+
+// wrappedScript returns every value of a list that begins with one of the given prefixes.
+var wrappedScript = redis.NewScript(`
+local wrapped = {}
+for _, value in ipairs(redis.call("LRANGE", KEYS[1], 0, -1)) do
+	for _, prefix in ipairs(ARGV) do
+		if string.sub(value, 1, #prefix) == prefix then
+			table.insert(wrapped, value)
+			break
+		end
+	end
+end
+return wrapped
+`)
+
+// wrappedPrefixes returns the beginnings of stored values that may be envelopes: envelopes
+// themselves, and compressed or encrypted values, whose contents can't be told apart by Redis.
+func wrappedPrefixes() []any {
+	prefixes := []any{envelopePrefix, encryptionHeader}
+	for _, compression := range compressions {
+		prefixes = append(prefixes, compression.header())
+	}
+	return prefixes
+}
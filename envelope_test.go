@@ -0,0 +1,190 @@
+package taskqueue_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_Envelope(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEnvelope())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	t.Run("add", func(t *testing.T) {
+		err := queue.Add("one", "two", "three")
+		require.NoError(t, err)
+		raw, err := queue.Redis.LIndex(context.Background(), name, 0).Result()
+		require.NoError(t, err)
+		assert.Contains(t, raw, `"taskqueue_envelope":1`)
+	})
+	t.Run("get", func(t *testing.T) {
+		value, err := queue.Get(0)
+		require.NoError(t, err)
+		assert.Equal(t, "one", value)
+	})
+	t.Run("has", func(t *testing.T) {
+		assert.True(t, queue.Has("two"))
+		assert.False(t, queue.Has("four"))
+	})
+	t.Run("remove", func(t *testing.T) {
+		err := queue.Remove("two")
+		require.NoError(t, err)
+		assert.False(t, queue.Has("two"))
+		assert.Equal(t, uint64(2), queue.Size())
+	})
+	t.Run("pop", func(t *testing.T) {
+		assert.Equal(t, "one", *queue.Pop())
+		assert.Equal(t, "three", *queue.Pop())
+	})
+	t.Run("reserve", func(t *testing.T) {
+		err := queue.Add("four")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		assert.Equal(t, "four", task.String())
+		env := task.Envelope()
+		require.NotNil(t, env)
+		assert.Len(t, env.ID, 32)
+		assert.Equal(t, 0, env.Attempts)
+		assert.WithinDuration(t, time.Now(), env.EnqueuedAt, time.Minute)
+		require.NoError(t, task.Ack())
+	})
+}
+
+func TestJSONTaskQueue_MaxAttempts(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		String string `json:"string"`
+	}
+
+	t.Run("fail", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithMaxAttempts(2), taskqueue.WithConsumer("consumer"))
+		require.NoError(t, err)
+		dlq, err := taskqueue.NewJSON(name+":dead-letter", ctx, addr)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			queue.Clear()
+			dlq.Clear()
+		})
+
+		err = queue.Add(Value{String: "value"})
+		require.NoError(t, err)
+
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		err = task.Fail(errors.New("first failure"))
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+
+		task, err = queue.Reserve()
+		require.NoError(t, err)
+		assert.Equal(t, 1, task.Envelope().Attempts)
+		assert.Equal(t, "first failure", task.Envelope().LastError)
+		var value *Value
+		require.NoError(t, task.Decode(&value))
+		assert.Equal(t, "value", value.String)
+		err = task.Fail(errors.New("second failure"))
+		require.NoError(t, err)
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, int64(0), queue.Redis.LLen(context.Background(), name+":processing:consumer").Val())
+
+		var letter *taskqueue.DeadLetter
+		err = dlq.Pop(&letter)
+		require.NoError(t, err)
+		assert.Equal(t, "second failure", letter.Error)
+		assert.Contains(t, string(letter.Payload), `"attempts":2`)
+	})
+
+	t.Run("nack", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithMaxAttempts(1))
+		require.NoError(t, err)
+		dlq, err := taskqueue.NewJSON(name+":dead-letter", ctx, addr)
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			queue.Clear()
+			dlq.Clear()
+		})
+
+		err = queue.Add(Value{String: "value"})
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, task.Nack())
+		assert.Equal(t, zero, queue.Size())
+
+		var letter *taskqueue.DeadLetter
+		err = dlq.Pop(&letter)
+		require.NoError(t, err)
+		assert.Equal(t, taskqueue.ErrMaxAttempts.Error(), letter.Error)
+	})
+
+	t.Run("pop", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithMaxAttempts(2))
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			queue.Clear()
+			queue.Redis.Del(context.Background(), name+":dead-letter")
+		})
+
+		err = queue.Add(RetryValue{"value"})
+		require.NoError(t, err)
+		var popped *RetryValue
+		err = queue.Pop(&popped)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+		err = queue.Pop(&popped)
+		var dlErr *taskqueue.DeadLetterError
+		require.ErrorAs(t, err, &dlErr)
+		assert.Equal(t, name+":dead-letter", dlErr.Queue)
+		assert.Equal(t, zero, queue.Size())
+	})
+
+	t.Run("fail without retry", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithNoRetry())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			queue.Clear()
+		})
+
+		err = queue.Add(Value{String: "value"})
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, task.Fail(errors.New("failure")))
+		assert.Equal(t, zero, queue.Size())
+		assert.ErrorIs(t, task.Ack(), taskqueue.ErrNotHeld)
+	})
+}
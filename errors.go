@@ -0,0 +1,14 @@
+package taskqueue
+
+import "github.com/pkg/errors"
+
+// ErrEmpty is returned when a task is requested from a queue that has no tasks.
+var ErrEmpty = errors.New("no values remain in queue")
+
+// ErrTimeout is returned by blocking operations when no task becomes available before the timeout
+// elapses. It is never returned for Redis errors.
+var ErrTimeout = errors.New("timed out waiting for a task")
+
+// ErrNotHeld is returned when acknowledging a reserved task that is no longer in the consumer's
+// processing list.
+var ErrNotHeld = errors.New("task is no longer held by this consumer")
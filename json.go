@@ -2,22 +2,23 @@ package taskqueue
 
 import (
 	"context"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 )
 
-// JSONTaskQueue implements a FIFO task queue with any JSON-able value as the value.
+// JSONTaskQueue implements a FIFO task queue with any JSON-able value as the value. Values may be
+// encoded with a different Codec using WithCodec.
 type JSONTaskQueue struct {
 	// Name represents the Redis key.
 	Name string
 	// Redis is the underlying Redis instance.
-	Redis   redis.UniversalClient
-	ctx     context.Context
-	noRetry bool
+	Redis redis.UniversalClient
+	ctx   context.Context
+	store *store
+	codec Codec
 }
 
 // NewJSON creates a new JSONTaskQueue instance.
@@ -26,32 +27,27 @@ func NewJSON(name string, option ...Option) (*JSONTaskQueue, error) {
 	if err != nil {
 		return nil, err
 	}
-	redisOptions := &redis.Options{
-		Addr:         options.Host,
-		Username:     options.Username,
-		Password:     options.Password,
-		TLSConfig:    options.TLSConfig,
-		ReadTimeout:  options.Timeout,
-		WriteTimeout: options.Timeout,
-		Network:      "tcp",
-	}
-	redisClient := redis.NewClient(redisOptions)
-	_, err = redisClient.Ping(options.Context).Result()
+	redisClient, err := newRedisClient(options)
 	if err != nil {
 		return nil, err
 	}
 	taskQueue := &JSONTaskQueue{
-		Name:    name,
-		Redis:   redisClient,
-		ctx:     options.Context,
-		noRetry: options.NoRetry,
+		Name:  name,
+		Redis: redisClient,
+		ctx:   options.Context,
+		store: newStore(redisClient, name, options),
+		codec: options.Codec,
+	}
+	err = taskQueue.store.register(QueueTypeJSON, options.Codec.ContentType())
+	if err != nil {
+		return nil, err
 	}
 	return taskQueue, nil
 }
 
 // Size returns the number of items in the queue.
 func (q *JSONTaskQueue) Size() uint64 {
-	zcard := q.Redis.LLen(q.ctx, q.Name)
+	zcard := q.Redis.LLen(q.ctx, q.store.queue)
 	size, err := zcard.Uint64()
 	if err != nil {
 		return 0
@@ -61,107 +57,226 @@ func (q *JSONTaskQueue) Size() uint64 {
 
 // Has determines if a queue has an given task.
 func (q *JSONTaskQueue) Has(value any) bool {
-	bValue, err := json.Marshal(value)
+	bValue, err := q.codec.Marshal(value)
 	if err != nil {
 		return false
 	}
-	sValue := string(bValue)
-	count, err := q.Redis.LPosCount(q.ctx, q.Name, sValue, 0, redis.LPosArgs{}).Result()
-	if err != nil {
-		return false
-	}
-	return len(count) > 0
+	has, err := q.store.has(bValue)
+	return err == nil && has
 }
 
-// Add adds any number of tasks to the queue in order. Items provided will be marshaled to JSON.
+// Add adds any number of tasks to the queue in order with a single command. Items provided will be
+// marshaled with the queue's Codec.
 func (q *JSONTaskQueue) Add(tasks ...any) error {
+	_, err := q.AddBatch(tasks...)
+	return err
+}
+
+// AddBatch adds any number of tasks to the queue in order with a single command, and returns the
+// number of tasks added. Either every task is added, or, if any task can't be marshaled or the
+// command fails, none are.
+func (q *JSONTaskQueue) AddBatch(tasks ...any) (int64, error) {
 	if len(tasks) == 0 {
-		return nil
+		return 0, nil
+	}
+	bTasks, err := q.marshal(tasks)
+	if err != nil {
+		return 0, err
+	}
+	values := make([]any, len(bTasks))
+	for i, bTask := range bTasks {
+		values[i] = bTask
+	}
+	err = q.Redis.RPush(q.ctx, q.store.queue, values...).Err()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(values)), nil
+}
+
+// AddUnique adds a task to the queue unless a task added with the same key is still pending, and
+// determines if it was added. The key is released once the task is popped, removed, or acknowledged,
+// after which a task with the same key may be added again. The task is stored in an envelope, even
+// if envelopes aren't enabled. While such tasks are pending, Has and Remove also read every stored
+// value that may be an envelope and compare it on the client, which is slower than the lookup Redis
+// does on its own for tasks stored without one.
+func (q *JSONTaskQueue) AddUnique(key string, task any) (bool, error) {
+	bTask, err := q.codec.Marshal(task)
+	if err != nil {
+		return false, err
 	}
-	bTasks := [][]byte{}
+	return q.store.addUnique(key, bTask)
+}
+
+// AddWithTTL adds any number of tasks to the queue in order, which expire once ttl has elapsed.
+// Expired tasks are discarded instead of being popped or reserved, or moved to the expired queue if
+// one is set with WithExpiredQueue. The tasks are stored in envelopes, even if envelopes aren't
+// enabled, so Has and Remove only find them if envelopes are enabled with WithEnvelope.
+func (q *JSONTaskQueue) AddWithTTL(ttl time.Duration, tasks ...any) error {
+	payloads := make([][]byte, 0, len(tasks))
 	for _, task := range tasks {
-		bTask, err := json.Marshal(task)
+		payload, err := q.codec.Marshal(task)
 		if err != nil {
 			return err
 		}
-		bTasks = append(bTasks, bTask)
+		payloads = append(payloads, payload)
 	}
-	added := int64(0)
-	for _, task := range bTasks {
-		a, err := q.Redis.RPush(q.ctx, q.Name, task).Result()
+	return q.store.addWithTTL(ttl, payloads)
+}
+
+// AddAt adds any number of tasks to the queue in order once the given time is reached. Until then,
+// the tasks are not included in Size, and are counted by ScheduledSize instead.
+func (q *JSONTaskQueue) AddAt(at time.Time, tasks ...any) error {
+	bTasks, err := q.marshal(tasks)
+	if err != nil {
+		return err
+	}
+	return q.store.schedule(at, bTasks)
+}
+
+// AddIn adds any number of tasks to the queue in order once the given duration has elapsed.
+func (q *JSONTaskQueue) AddIn(delay time.Duration, tasks ...any) error {
+	return q.AddAt(time.Now().Add(delay), tasks...)
+}
+
+// ScheduledSize returns the number of tasks added with AddAt or AddIn that are not yet due.
+func (q *JSONTaskQueue) ScheduledSize() uint64 {
+	return q.store.scheduledSize()
+}
+
+// Promote adds every scheduled task that is due to the queue and returns the number of tasks
+// added. Due tasks are promoted automatically before tasks are popped or reserved, so calling
+// Promote is only necessary to keep Size accurate.
+func (q *JSONTaskQueue) Promote() (int, error) {
+	return q.store.promote()
+}
+
+// marshal returns the stored values of tasks.
+func (q *JSONTaskQueue) marshal(tasks []any) ([][]byte, error) {
+	bTasks := make([][]byte, 0, len(tasks))
+	for _, task := range tasks {
+		bTask, err := q.codec.Marshal(task)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		added += a
-	}
-	if added == 0 {
-		return fmt.Errorf("failed to add tasks to queue")
+		bTask, err = q.store.seal(bTask)
+		if err != nil {
+			return nil, err
+		}
+		bTasks = append(bTasks, bTask)
 	}
-	return nil
+	return bTasks, nil
 }
 
 // Pop removes the first task from the queue and unmarshals the value.
 func (q *JSONTaskQueue) Pop(value any) error {
-	pop := q.Redis.LPop(q.ctx, q.Name)
-	popped, err := pop.Bytes()
+	popped, err := q.store.pop()
 	if err != nil {
-		if err == redis.Nil {
-			return fmt.Errorf("no values remain in queue")
-		}
 		return err
 	}
-	err = json.Unmarshal(popped, value)
+	return q.unmarshal(popped, value)
+}
+
+// PopN removes up to n tasks from the front of the queue in a single round trip, and unmarshals them
+// into values, which must be a pointer to a slice. If the queue is empty, values is set to an empty
+// slice. If some tasks can't be unmarshaled, the rest are unmarshaled as usual, the tasks that
+// failed are handled the same way as they would be by Pop and left as zero values, and a
+// *BatchError is returned.
+func (q *JSONTaskQueue) PopN(n int, values any) error {
+	_, err := sliceTarget(values)
+	if err != nil {
+		return err
+	}
+	popped, err := q.store.popN(n)
+	if err != nil {
+		return err
+	}
+	return q.store.decodeSlice(popped, true, values, q.codec.Unmarshal)
+}
+
+// BlockingPop removes the first task from the queue and unmarshals the value, waiting for a task to
+// be added if the queue is empty. If no task arrives before the timeout elapses, ErrTimeout is
+// returned. If ctx is cancelled first, the context's error is returned. A timeout of zero waits
+// indefinitely. Redis waits in steps of one second, so a timeout or cancellation may take up to a
+// second longer to be noticed. If the task can't be unmarshaled, the error is returned, even if the
+// task was re-added to the queue.
+func (q *JSONTaskQueue) BlockingPop(ctx context.Context, timeout time.Duration, value any) error {
+	popped, err := q.store.blockingPop(ctx, timeout)
 	if err != nil {
-		if !q.noRetry {
-			added, err := q.Redis.RPush(q.ctx, q.Name, popped).Result()
-			if err != nil {
-				return errors.Wrap(err, "failed to re-add task to queue after unmarshal failure")
-			}
-			if added == 0 {
-				return fmt.Errorf("failed to re-add task to queue after unmarshal failure")
-			}
-			return nil
-		}
 		return err
 	}
+	return q.deliver(popped, value)
+}
+
+// unmarshal unmarshals a popped task. If the task can't be unmarshaled, it is moved to the
+// dead-letter queue if one is set, or otherwise re-added to the queue unless retries are disabled.
+func (q *JSONTaskQueue) unmarshal(popped []byte, value any) error {
+	payload, env, err := q.store.open(popped)
+	if err == nil {
+		err = q.codec.Unmarshal(payload, value)
+	}
+	if err != nil {
+		return q.store.popFailed(popped, env, err)
+	}
+	return nil
+}
+
+// deliver unmarshals a popped task the same way as unmarshal, but returns the error even if the task
+// was re-added to the queue.
+func (q *JSONTaskQueue) deliver(popped []byte, value any) error {
+	payload, env, err := q.store.open(popped)
+	if err == nil {
+		err = q.codec.Unmarshal(payload, value)
+	}
+	if err != nil {
+		return q.store.undelivered(popped, env, err)
+	}
 	return nil
 }
 
 func (q *JSONTaskQueue) PopBytes() ([]byte, error) {
-	return q.Redis.LPop(q.ctx, q.Name).Bytes()
+	popped, err := q.store.lpop()
+	if err != nil {
+		return nil, err
+	}
+	payload, env, err := q.store.open(popped)
+	if err != nil {
+		return nil, q.store.popFailed(popped, env, err)
+	}
+	return payload, nil
 }
 
 // Remove removes a task from the queue.
 func (q *JSONTaskQueue) Remove(task any) error {
-	bTask, err := json.Marshal(task)
-	if err != nil {
-		return err
-	}
-	_, err = q.Redis.LRem(q.ctx, q.Name, 0, bTask).Result()
+	bTask, err := q.codec.Marshal(task)
 	if err != nil {
 		return err
 	}
-	return nil
+	return q.store.remove(bTask)
 }
 
-// Clear removes all tasks from the queue.
+// Clear removes all tasks from the queue, including scheduled tasks.
 func (q *JSONTaskQueue) Clear() error {
-	_, err := q.Redis.Del(q.ctx, q.Name).Result()
+	_, err := q.Redis.Del(q.ctx, q.store.queue, q.store.scheduled, q.store.unique).Result()
 	return err
 }
 
 func (q *JSONTaskQueue) Get(index int64, target any) error {
-	value, err := q.Redis.LIndex(q.ctx, q.Name, index).Bytes()
+	value, err := q.Redis.LIndex(q.ctx, q.store.queue, index).Bytes()
 	if err != nil {
 		if err == redis.Nil {
 			return fmt.Errorf("index %d does not exist in queue", index)
 		}
 		return err
 	}
-	err = json.Unmarshal(value, target)
+	payload, _, err := q.store.open(value)
 	if err != nil {
-		if !q.noRetry {
-			_, err := q.Redis.LSet(q.ctx, q.Name, index, value).Result()
+		return err
+	}
+	err = q.codec.Unmarshal(payload, target)
+	if err != nil {
+		if !q.store.noRetry {
+			_, err := q.Redis.LSet(q.ctx, q.store.queue, index, value).Result()
 			if err != nil {
 				return errors.Wrap(err, "failed to re-add task to queue after unmarshal failure")
 			}
@@ -172,25 +287,88 @@ func (q *JSONTaskQueue) Get(index int64, target any) error {
 	return nil
 }
 
-func (q *JSONTaskQueue) RemoveIndex(index int64) error {
-	value, err := q.Redis.LIndex(q.ctx, q.Name, index).Result()
+// Peek unmarshals the first task of the queue into value without removing it. If the queue is
+// empty, ErrEmpty is returned.
+func (q *JSONTaskQueue) Peek(value any) error {
+	stored, err := q.store.peek()
 	if err != nil {
-		if err == redis.Nil {
-			return fmt.Errorf("index %d does not exist in queue", index)
-		}
 		return err
 	}
-	encoded := base64.StdEncoding.EncodeToString([]byte(value))
-	_, err = q.Redis.LSet(q.ctx, q.Name, index, encoded).Result()
+	payload, _, err := q.store.open(stored)
 	if err != nil {
 		return err
 	}
-	removed, err := q.Redis.LRem(q.ctx, q.Name, 1, encoded).Result()
+	return q.codec.Unmarshal(payload, value)
+}
+
+// Range unmarshals the tasks of the queue from index start to stop, inclusive, into values, which
+// must be a pointer to a slice, without removing them. Negative indexes count from the end of the
+// queue, so Range(0, -1, &values) unmarshals every task. If some tasks can't be unmarshaled, the rest
+// are unmarshaled as usual, and a *BatchError is returned.
+func (q *JSONTaskQueue) Range(start, stop int64, values any) error {
+	_, err := sliceTarget(values)
 	if err != nil {
 		return err
 	}
-	if removed == 0 {
-		return fmt.Errorf("failed to remove value item %d from queue", index)
+	stored, err := q.store.rangeValues(start, stop)
+	if err != nil {
+		return err
 	}
-	return nil
+	return q.store.decodeSlice(stored, false, values, q.codec.Unmarshal)
+}
+
+// List returns a Cursor that iterates over the tasks of the queue, pageSize tasks at a time,
+// without removing them.
+func (q *JSONTaskQueue) List(pageSize int64) *Cursor {
+	return q.store.list(pageSize, q.codec.Unmarshal)
+}
+
+// RemoveIndex atomically removes the task at an index of the queue, and unmarshals it into target
+// unless target is nil. The task is removed even if it can't be unmarshaled.
+func (q *JSONTaskQueue) RemoveIndex(index int64, target any) error {
+	value, err := q.store.removeIndex(index)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return nil
+	}
+	payload, _, err := q.store.open(value)
+	if err != nil {
+		return err
+	}
+	return q.codec.Unmarshal(payload, target)
+}
+
+// Reserve atomically moves the first task from the queue to the consumer's processing list and
+// returns it. The task must be acknowledged with Ack once it has been handled, or returned to the
+// queue with Nack. If the queue is empty, ErrEmpty is returned.
+func (q *JSONTaskQueue) Reserve() (*Task, error) {
+	return q.ReserveContext(q.ctx)
+}
+
+// ReserveContext is the same as Reserve, but stops waiting for the rate limit set with
+// WithRateLimit once ctx is done, in which case the context's error is returned.
+func (q *JSONTaskQueue) ReserveContext(ctx context.Context) (*Task, error) {
+	raw, lease, err := q.store.reserve(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return q.task(raw, lease), nil
+}
+
+// BlockingReserve is the same as Reserve, but waits for a task to be added if the queue is empty.
+// If no task arrives before the timeout elapses, ErrTimeout is returned. If ctx is cancelled first,
+// the context's error is returned. A timeout of zero waits indefinitely. Redis waits in steps of one
+// second, so a timeout or cancellation may take up to a second longer to be noticed.
+func (q *JSONTaskQueue) BlockingReserve(ctx context.Context, timeout time.Duration) (*Task, error) {
+	raw, lease, err := q.store.blockingReserve(ctx, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return q.task(raw, lease), nil
+}
+
+func (q *JSONTaskQueue) task(raw []byte, lease string) *Task {
+	return q.store.task(raw, lease, q.codec.Unmarshal)
 }
@@ -1,6 +1,7 @@
 package taskqueue_test
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"testing"
@@ -447,8 +448,10 @@ func TestJSONTaskQueue_RemoveIndex(t *testing.T) {
 		require.NoError(t, err)
 	})
 	t.Run("remove value by index", func(t *testing.T) {
-		err := queue.RemoveIndex(0)
+		var removed *Value
+		err := queue.RemoveIndex(0, &removed)
 		require.NoError(t, err)
+		assert.Equal(t, value, *removed)
 	})
 	t.Run("ensure value is removed via get", func(t *testing.T) {
 		var popped *Value
@@ -463,7 +466,7 @@ func TestJSONTaskQueue_RemoveIndex(t *testing.T) {
 		assert.Nil(t, popped)
 	})
 	t.Run("ensure no removed items errors", func(t *testing.T) {
-		err := queue.RemoveIndex(5)
+		err := queue.RemoveIndex(5, nil)
 		require.Error(t, err)
 	})
 }
@@ -506,3 +509,103 @@ func TestJSONTaskQueue_Has(t *testing.T) {
 		require.False(t, has)
 	})
 }
+
+func TestJSONTaskQueue_BlockingPop(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, addr)
+	defer queue.Clear()
+	require.NoError(t, err)
+
+	type Value struct {
+		String string `json:"string"`
+		Number int    `json:"number"`
+	}
+
+	t.Run("existing value", func(t *testing.T) {
+		err := queue.Add(Value{String: "one", Number: 1})
+		require.NoError(t, err)
+		var popped *Value
+		err = queue.BlockingPop(context.Background(), time.Second, &popped)
+		require.NoError(t, err)
+		assert.Equal(t, "one", popped.String)
+	})
+	t.Run("waits for value", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			queue.Add(Value{String: "two", Number: 2})
+		}()
+		var popped *Value
+		err := queue.BlockingPop(context.Background(), time.Second*5, &popped)
+		require.NoError(t, err)
+		assert.Equal(t, 2, popped.Number)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		var popped *Value
+		err := queue.BlockingPop(context.Background(), time.Second, &popped)
+		assert.ErrorIs(t, err, taskqueue.ErrTimeout)
+		assert.Nil(t, popped)
+	})
+	t.Run("cancelled", func(t *testing.T) {
+		c, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			cancel()
+		}()
+		var popped *Value
+		err := queue.BlockingPop(c, 0, &popped)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+	t.Run("invalid value", func(t *testing.T) {
+		require.NoError(t, queue.Add("invalid"))
+		var popped Value
+		err := queue.BlockingPop(context.Background(), time.Second, &popped)
+		assert.Error(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+		require.NoError(t, queue.Clear())
+	})
+}
+
+func TestJSONTaskQueue_AddBatch(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, addr)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	t.Run("add batch", func(t *testing.T) {
+		added, err := queue.AddBatch("one", "two", "three")
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), added)
+		added, err = queue.AddBatch("four")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), added)
+		assert.Equal(t, uint64(4), queue.Size())
+	})
+	t.Run("nothing added on failure", func(t *testing.T) {
+		added, err := queue.AddBatch("five", make(chan int), "six")
+		require.Error(t, err)
+		assert.Equal(t, int64(0), added)
+		err = queue.Add("five", make(chan int), "six")
+		require.Error(t, err)
+		assert.Equal(t, uint64(4), queue.Size())
+	})
+}
@@ -0,0 +1,144 @@
+package taskqueue
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// keyspace builds the Redis keys of queues. By default, a queue's name is used as its key, and its
+// other keys are named after it. When a prefix is set or Redis Cluster is used, keys are prefixed,
+// and queue names are wrapped in a hash tag so that every key of a queue is in the same slot.
+type keyspace struct {
+	prefix string
+	tagged bool
+}
+
+// newKeyspace creates the keyspace for the given options and client.
+func newKeyspace(options *Options, client redis.UniversalClient) keyspace {
+	_, cluster := client.(*redis.ClusterClient)
+	return keyspace{
+		prefix: options.KeyPrefix,
+		tagged: options.KeyPrefix != "" || cluster,
+	}
+}
+
+// queue returns the Redis key of the list of a queue's tasks. Names that already contain a hash
+// tag, such as "{orders}:retry", keep it, so that related queues can share a slot.
+func (k keyspace) queue(name string) string {
+	if !k.tagged {
+		return name
+	}
+	if !hasHashTag(name) {
+		name = "{" + name + "}"
+	}
+	return k.global(name)
+}
+
+// owned returns the Redis key of a queue that belongs to the queue with the given name, such as
+// its dead-letter queue, or an empty string if no owned queue is given. Tasks are moved between a
+// queue and the queues it owns in a single script, so when keys are tagged, an owned queue that
+// doesn't already share the queue's hash tag is kept under the queue's key, for example
+// "prefix:{name}:dead-letter:owned".
+func (k keyspace) owned(name, kind, owned string) string {
+	if owned == "" {
+		return ""
+	}
+	if !k.tagged {
+		return owned
+	}
+	key := k.queue(owned)
+	if hashTag(key) == hashTag(k.queue(name)) {
+		return key
+	}
+	return k.key(name, kind, owned)
+}
+
+// key returns the Redis key of one of a queue's other keys, such as its processing list.
+func (k keyspace) key(name string, parts ...string) string {
+	return strings.Join(append([]string{k.queue(name)}, parts...), ":")
+}
+
+// global returns the Redis key of a key that doesn't belong to any queue.
+func (k keyspace) global(key string) string {
+	if k.prefix == "" {
+		return key
+	}
+	return k.prefix + ":" + key
+}
+
+// processing returns the Redis key of a consumer's processing list for a queue.
+func (k keyspace) processing(name, consumer string) string {
+	return k.key(name, "processing", consumer)
+}
+
+// leases returns the Redis key of the sorted set of leases for a queue, scored by the time at
+// which each lease expires.
+func (k keyspace) leases(name string) string {
+	return k.key(name, "leases")
+}
+
+// scheduled returns the Redis key of the sorted set of a queue's scheduled tasks, scored by the
+// time at which each task is due.
+func (k keyspace) scheduled(name string) string {
+	return k.key(name, "scheduled")
+}
+
+// priority returns the Redis key of the list of a priority queue's tasks with a given priority.
+func (k keyspace) priority(name string, priority int) string {
+	return k.key(name, "priority", fmt.Sprint(priority))
+}
+
+// rateLimit returns the Redis key of the token bucket that limits the rate at which a queue's
+// tasks are consumed.
+func (k keyspace) rateLimit(name string) string {
+	return k.key(name, "rate-limit")
+}
+
+// unique returns the Redis key of the set of keys of a queue's pending tasks that were added with
+// AddUnique.
+func (k keyspace) unique(name string) string {
+	return k.key(name, "unique")
+}
+
+// deadLetter returns the Redis key of a queue's default dead-letter queue.
+func (k keyspace) deadLetter(name string) string {
+	return k.key(name, "dead-letter")
+}
+
+// metadata returns the Redis key of the hash of a queue's metadata recorded in the registry.
+func (k keyspace) metadata(name string) string {
+	return k.key(name, "meta")
+}
+
+// registry returns the Redis key of the set of the names of every registered queue.
+func (k keyspace) registry() string {
+	return k.global("taskqueue:queues")
+}
+
+// schedulerLock returns the Redis key of the lock for a recurring task's run at tick. Ticks are
+// identified by millisecond, so that runs less than a second apart have their own locks.
+func (k keyspace) schedulerLock(name string, tick time.Time) string {
+	return k.global(fmt.Sprintf("taskqueue:scheduler:%s:%d", name, tick.UnixMilli()))
+}
+
+// hasHashTag determines if a key contains a Redis Cluster hash tag.
+func hasHashTag(key string) bool {
+	return hashTag(key) != ""
+}
+
+// hashTag returns the Redis Cluster hash tag of a key, the non-empty substring between the first
+// "{" and the next "}", or an empty string if the key has none.
+func hashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return ""
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return ""
+	}
+	return key[start+1 : start+1+end]
+}
@@ -0,0 +1,71 @@
+package taskqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// keySlot returns the Redis Cluster slot of a key.
+func keySlot(key string) uint16 {
+	if tag := hashTag(key); tag != "" {
+		key = tag
+	}
+	var crc uint16
+	for i := 0; i < len(key); i++ {
+		crc ^= uint16(key[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc % 16384
+}
+
+func Test_keyspace(t *testing.T) {
+	t.Run("legacy", func(t *testing.T) {
+		keys := keyspace{}
+		assert.Equal(t, "orders", keys.queue("orders"))
+		assert.Equal(t, "orders:processing:worker", keys.processing("orders", "worker"))
+		assert.Equal(t, "failed", keys.owned("orders", "dead-letter", "failed"))
+		assert.Equal(t, "", keys.owned("orders", "dead-letter", ""))
+	})
+
+	t.Run("tagged", func(t *testing.T) {
+		keys := keyspace{prefix: "app", tagged: true}
+		assert.Equal(t, "app:{orders}", keys.queue("orders"))
+		assert.Equal(t, "app:{orders}:retry", keys.queue("{orders}:retry"))
+		assert.Equal(t, "app:{orders}:dead-letter:failed", keys.owned("orders", "dead-letter", "failed"))
+		assert.Equal(t, "app:{orders}:failed", keys.owned("orders", "dead-letter", "{orders}:failed"))
+		assert.Equal(t, "app:{orders}:expired:{other}", keys.owned("orders", "expired", "{other}"))
+	})
+
+	t.Run("one slot per queue", func(t *testing.T) {
+		keys := keyspace{prefix: "app", tagged: true}
+		slot := keySlot(keys.queue("orders"))
+		for _, key := range []string{
+			keys.processing("orders", "worker"),
+			keys.leases("orders"),
+			keys.scheduled("orders"),
+			keys.priority("orders", 3),
+			keys.rateLimit("orders"),
+			keys.unique("orders"),
+			keys.deadLetter("orders"),
+			keys.metadata("orders"),
+			keys.owned("orders", "dead-letter", "failed"),
+			keys.owned("orders", "dead-letter", "{orders}:failed"),
+			keys.owned("orders", "expired", "expired"),
+			keys.owned("orders", "expired", "{other}"),
+		} {
+			assert.Equal(t, slot, keySlot(key), key)
+		}
+	})
+}
+
+func Test_keySlot(t *testing.T) {
+	assert.Equal(t, uint16(12182), keySlot("foo"))
+	assert.Equal(t, keySlot("foo"), keySlot("{foo}:bar"))
+}
@@ -0,0 +1,122 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WithKeyPrefix(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+	prefix := taskqueue.WithKeyPrefix("app")
+
+	t.Run("queue key", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, prefix)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("value"))
+		size, err := queue.Redis.LLen(context.Background(), fmt.Sprintf("app:{%s}", name)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+		exists, err := queue.Redis.Exists(context.Background(), name).Result()
+		require.NoError(t, err)
+		assert.Zero(t, exists)
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+
+	t.Run("companion keys", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, prefix, taskqueue.WithConsumer("worker"), taskqueue.WithMaxAttempts(1))
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.AddIn(time.Hour, "later"))
+		size, err := queue.Redis.ZCard(context.Background(), fmt.Sprintf("app:{%s}:scheduled", name)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+
+		require.NoError(t, queue.Add("value"))
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		size, err = queue.Redis.LLen(context.Background(), fmt.Sprintf("app:{%s}:processing:worker", name)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+
+		require.NoError(t, task.Nack())
+		size, err = queue.Redis.LLen(context.Background(), fmt.Sprintf("app:{%s}:dead-letter", name)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+	})
+
+	t.Run("existing hash tag", func(t *testing.T) {
+		name := fmt.Sprintf("{%s}:retry", t.Name())
+		queue, err := taskqueue.NewBasic(name, ctx, addr, prefix)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("value"))
+		size, err := queue.Redis.LLen(context.Background(), "app:"+name).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+	})
+
+	t.Run("priority", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewPriority(name, ctx, addr, prefix)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(3, "value"))
+		size, err := queue.Redis.LLen(context.Background(), fmt.Sprintf("app:{%s}:priority:3", name)).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+	})
+
+	t.Run("no prefix", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("value"))
+		size, err := queue.Redis.LLen(context.Background(), name).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), size)
+	})
+}
+
+func Test_ClusterKeys(t *testing.T) {
+	mr := RunT(t)
+	if !UseMini {
+		t.Skip("requires a Redis Cluster")
+	}
+	ctx := taskqueue.WithContext(mr.Ctx)
+	cluster := taskqueue.WithClusterAddrs(mr.Addr())
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, cluster, taskqueue.WithConsumer("worker"))
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.Add("value"))
+	assert.True(t, mr.Exists(fmt.Sprintf("{%s}", name)))
+	task, err := queue.Reserve()
+	require.NoError(t, err)
+	assert.True(t, mr.Exists(fmt.Sprintf("{%s}:processing:worker", name)))
+	require.NoError(t, task.Ack())
+}
@@ -0,0 +1,96 @@
+package taskqueue
+
+import (
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Cursor iterates over the tasks in a queue a page at a time, without removing them. Pages are
+// read by index, so tasks added or removed from the front of the queue while iterating may cause
+// tasks to be skipped or returned twice.
+type Cursor struct {
+	store    *store
+	decode   func(data []byte, value any) error
+	pageSize int64
+	start    int64
+	page     [][]byte
+	err      error
+	done     bool
+}
+
+// Next reads the next page of tasks, and determines if it has any. Once Next returns false, Err
+// reports the error that occurred, if any.
+func (c *Cursor) Next() bool {
+	if c.done || c.err != nil {
+		return false
+	}
+	c.page, c.err = c.store.rangeValues(c.start, c.start+c.pageSize-1)
+	if c.err != nil || len(c.page) == 0 {
+		c.done = true
+		return false
+	}
+	c.start += int64(len(c.page))
+	if int64(len(c.page)) < c.pageSize {
+		c.done = true
+	}
+	return true
+}
+
+// Strings returns the current page of tasks as strings. If some tasks can't be read, the rest are
+// returned along with a *BatchError.
+func (c *Cursor) Strings() ([]string, error) {
+	values := make([]string, len(c.page))
+	err := c.store.decodeBatch(c.page, false, func(i int, payload []byte) error {
+		values[i] = string(payload)
+		return nil
+	})
+	return values, err
+}
+
+// Decode unmarshals the current page of tasks into values, which must be a pointer to a slice, the
+// same way the queue would when popping them. If some tasks can't be unmarshaled, the rest are
+// unmarshaled as usual, and a *BatchError is returned.
+func (c *Cursor) Decode(values any) error {
+	return c.store.decodeSlice(c.page, false, values, c.decode)
+}
+
+// Err returns the error that stopped iteration, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// list creates a Cursor over the queue's tasks.
+func (s *store) list(pageSize int64, decode func(data []byte, value any) error) *Cursor {
+	cursor := &Cursor{store: s, decode: decode, pageSize: pageSize}
+	if pageSize < 1 {
+		cursor.err = fmt.Errorf("page size must be at least 1")
+	}
+	return cursor
+}
+
+// peek returns the first stored value of the queue without removing it. If the queue is empty,
+// ErrEmpty is returned.
+func (s *store) peek() ([]byte, error) {
+	value, err := s.redis.LIndex(s.ctx, s.queue, 0).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrEmpty
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// rangeValues returns the stored values of the queue from start to stop, inclusive.
+func (s *store) rangeValues(start, stop int64) ([][]byte, error) {
+	values, err := s.redis.LRange(s.ctx, s.queue, start, stop).Result()
+	if err != nil {
+		return nil, err
+	}
+	stored := make([][]byte, len(values))
+	for i, value := range values {
+		stored[i] = []byte(value)
+	}
+	return stored, nil
+}
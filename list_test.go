@@ -0,0 +1,190 @@
+package taskqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_Peek(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithEnvelope())
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	_, err = queue.Peek()
+	assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+
+	require.NoError(t, queue.Add("one", "two"))
+	value, err := queue.Peek()
+	require.NoError(t, err)
+	assert.Equal(t, "one", value)
+	assert.Equal(t, uint64(2), queue.Size())
+}
+
+func TestBasicTaskQueue_Range(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.Add("one", "two", "three"))
+	values, err := queue.Range(0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"one", "two", "three"}, values)
+	values, err = queue.Range(1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"two"}, values)
+	assert.Equal(t, uint64(3), queue.Size())
+}
+
+func TestBasicTaskQueue_List(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	t.Run("pages", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("one", "two", "three", "four", "five"))
+		cursor := queue.List(2)
+		var pages [][]string
+		for cursor.Next() {
+			page, err := cursor.Strings()
+			require.NoError(t, err)
+			pages = append(pages, page)
+		}
+		require.NoError(t, cursor.Err())
+		assert.Equal(t, [][]string{{"one", "two"}, {"three", "four"}, {"five"}}, pages)
+		assert.Equal(t, uint64(5), queue.Size())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		cursor := queue.List(2)
+		assert.False(t, cursor.Next())
+		assert.NoError(t, cursor.Err())
+	})
+
+	t.Run("invalid page size", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		cursor := queue.List(0)
+		assert.False(t, cursor.Next())
+		assert.Error(t, cursor.Err())
+	})
+}
+
+func TestJSONTaskQueue_Range(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		ID int `json:"id"`
+	}
+
+	t.Run("peek", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		var value Value
+		assert.ErrorIs(t, queue.Peek(&value), taskqueue.ErrEmpty)
+		require.NoError(t, queue.Add(Value{1}, Value{2}))
+		require.NoError(t, queue.Peek(&value))
+		assert.Equal(t, Value{1}, value)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(Value{1}, "invalid", Value{3}))
+		var values []Value
+		err = queue.Range(0, -1, &values)
+		var batchErr *taskqueue.BatchError
+		require.ErrorAs(t, err, &batchErr)
+		assert.Contains(t, batchErr.Errors, 1)
+		assert.Equal(t, []Value{{1}, {}, {3}}, values)
+		assert.Equal(t, uint64(3), queue.Size())
+	})
+
+	t.Run("list", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(Value{1}, Value{2}, Value{3}))
+		cursor := queue.List(2)
+		var all []Value
+		for cursor.Next() {
+			var page []Value
+			require.NoError(t, cursor.Decode(&page))
+			all = append(all, page...)
+		}
+		require.NoError(t, cursor.Err())
+		assert.Equal(t, []Value{{1}, {2}, {3}}, all)
+	})
+
+	t.Run("typed", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewTyped[Value](name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add(Value{1}, Value{2}))
+		value, err := queue.Peek()
+		require.NoError(t, err)
+		assert.Equal(t, Value{1}, value)
+		values, err := queue.Range(0, -1)
+		require.NoError(t, err)
+		assert.Equal(t, []Value{{1}, {2}}, values)
+	})
+}
@@ -0,0 +1,75 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// PanicError is returned by a handler wrapped with Recover when the handler panics.
+type PanicError struct {
+	// Value is the value the handler panicked with.
+	Value any
+	// Stack is the stack trace of the goroutine at the time of the panic.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("handler panicked: %v", e.Value)
+}
+
+// Logger logs messages with alternating key-value pairs, such as a *slog.Logger.
+type Logger interface {
+	Info(msg string, args ...any)
+	Error(msg string, args ...any)
+}
+
+// Recover returns middleware that recovers from panics in the handler. A task whose handler panics
+// fails with a *PanicError.
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, task *Task) (err error) {
+			defer func() {
+				if value := recover(); value != nil {
+					err = &PanicError{Value: value, Stack: debug.Stack()}
+				}
+			}()
+			return next.Handle(ctx, task)
+		})
+	}
+}
+
+// Timeout returns middleware that cancels the handler's context once the timeout elapses. Handlers
+// must return when their context is cancelled for the timeout to take effect.
+func Timeout(timeout time.Duration) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, task *Task) error {
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			return next.Handle(ctx, task)
+		})
+	}
+}
+
+// Logging returns middleware that logs the outcome of every task, along with the queue it was
+// reserved from, its ID if it has an envelope, and how long it took to handle.
+func Logging(logger Logger) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, task *Task) error {
+			start := time.Now()
+			err := next.Handle(ctx, task)
+			args := []any{"queue", task.Queue()}
+			if env := task.Envelope(); env != nil {
+				args = append(args, "id", env.ID, "attempts", env.Attempts)
+			}
+			args = append(args, "duration", time.Since(start))
+			if err != nil {
+				logger.Error("task failed", append(args, "error", err)...)
+			} else {
+				logger.Info("task handled", args...)
+			}
+			return err
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testLogger struct {
+	mu       sync.Mutex
+	messages []string
+	args     [][]any
+}
+
+func (l *testLogger) Info(msg string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages = append(l.messages, msg)
+	l.args = append(l.args, args)
+}
+
+func (l *testLogger) Error(msg string, args ...any) {
+	l.Info(msg, args...)
+}
+
+func TestMiddleware(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	// run handles a single task with handler wrapped by middleware, and returns the error the
+	// handler chain returned.
+	run := func(t *testing.T, handler taskqueue.HandlerFunc, middleware ...taskqueue.Middleware) error {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithNoRetry())
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			queue.Clear()
+		})
+		require.NoError(t, queue.Add("value"))
+		c, cancel := context.WithCancel(context.Background())
+		var result error
+		worker, err := taskqueue.NewWorker(queue, handler)
+		require.NoError(t, err)
+		worker.Use(func(next taskqueue.Handler) taskqueue.Handler {
+			return taskqueue.HandlerFunc(func(ctx context.Context, task *taskqueue.Task) error {
+				result = next.Handle(ctx, task)
+				cancel()
+				return result
+			})
+		})
+		worker.Use(middleware...)
+		worker.Run(c)
+		return result
+	}
+
+	t.Run("recover", func(t *testing.T) {
+		err := run(t, func(ctx context.Context, task *taskqueue.Task) error {
+			panic("oops")
+		}, taskqueue.Recover())
+		var panicErr *taskqueue.PanicError
+		require.ErrorAs(t, err, &panicErr)
+		assert.Equal(t, "oops", panicErr.Value)
+		assert.NotEmpty(t, panicErr.Stack)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		err := run(t, func(ctx context.Context, task *taskqueue.Task) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}, taskqueue.Timeout(time.Millisecond*50))
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("logging", func(t *testing.T) {
+		logger := &testLogger{}
+		err := run(t, func(ctx context.Context, task *taskqueue.Task) error {
+			return fmt.Errorf("failed")
+		}, taskqueue.Logging(logger))
+		require.Error(t, err)
+		require.Len(t, logger.messages, 1)
+		assert.Equal(t, "task failed", logger.messages[0])
+		assert.Contains(t, logger.args[0], "queue")
+		assert.Contains(t, logger.args[0], err)
+	})
+
+	t.Run("order", func(t *testing.T) {
+		order := []string{}
+		trace := func(name string) taskqueue.Middleware {
+			return func(next taskqueue.Handler) taskqueue.Handler {
+				return taskqueue.HandlerFunc(func(ctx context.Context, task *taskqueue.Task) error {
+					order = append(order, name)
+					return next.Handle(ctx, task)
+				})
+			}
+		}
+		err := run(t, func(ctx context.Context, task *taskqueue.Task) error {
+			order = append(order, "handler")
+			return nil
+		}, trace("first"), trace("second"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"first", "second", "handler"}, order)
+	})
+}
@@ -0,0 +1,223 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// priorityPopScript pops the first task from the first non-empty list, and returns the index of
+// the list along with the task.
+var priorityPopScript = redis.NewScript(`
+for i, key in ipairs(KEYS) do
+	local value = redis.call("LPOP", key)
+	if value then
+		return {i - 1, value}
+	end
+end
+return false
+`)
+
+// PriorityTaskQueue implements a task queue with any JSON-able value as the value, in which tasks
+// with a higher priority are always popped before tasks with a lower priority. Tasks with the same
+// priority are popped in the order they were added.
+type PriorityTaskQueue struct {
+	// Name represents the Redis key prefix of each priority level.
+	Name string
+	// Redis is the underlying Redis instance.
+	Redis      redis.UniversalClient
+	ctx        context.Context
+	keyspace   keyspace
+	noRetry    bool
+	deadLetter string
+	levels     int
+	codec      Codec
+}
+
+// NewPriority creates a new PriorityTaskQueue instance. Priorities range from zero, the lowest, to
+// one less than the number of levels set with WithPriorityLevels.
+func NewPriority(name string, option ...Option) (*PriorityTaskQueue, error) {
+	options, err := getOptions(option)
+	if err != nil {
+		return nil, err
+	}
+	if options.PriorityLevels < 1 {
+		return nil, fmt.Errorf("a priority queue must have at least one priority level")
+	}
+	err = priorityOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+	keys := newKeyspace(options, redisClient)
+	taskQueue := &PriorityTaskQueue{
+		Name:       name,
+		Redis:      redisClient,
+		ctx:        options.Context,
+		keyspace:   keys,
+		noRetry:    options.NoRetry,
+		deadLetter: keys.owned(name, "dead-letter", options.DeadLetterQueue),
+		levels:     options.PriorityLevels,
+		codec:      options.Codec,
+	}
+	return taskQueue, nil
+}
+
+// priorityOptions returns an error if an option that a priority queue doesn't support is set, so
+// that tasks aren't stored differently than expected.
+func priorityOptions(options *Options) error {
+	unsupported := []struct {
+		option string
+		set    bool
+	}{
+		{"WithEncryption", options.Keyring != nil},
+		{"WithCompression", options.Compression != ""},
+		{"WithEnvelope", options.Envelope},
+		{"WithMaxAttempts", options.MaxAttempts > 0},
+		{"WithRateLimit", options.RateLimit > 0},
+		{"WithExpiredQueue", options.ExpiredQueue != ""},
+		{"WithVisibilityTimeout", options.VisibilityTimeout > 0},
+	}
+	for _, u := range unsupported {
+		if u.set {
+			return fmt.Errorf("%s is not supported by a priority queue", u.option)
+		}
+	}
+	return nil
+}
+
+// Size returns the number of items in the queue across all priorities.
+func (q *PriorityTaskQueue) Size() uint64 {
+	cmds, err := q.Redis.Pipelined(q.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range q.keys() {
+			pipe.LLen(q.ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0
+	}
+	size := uint64(0)
+	for _, cmd := range cmds {
+		size += uint64(cmd.(*redis.IntCmd).Val())
+	}
+	return size
+}
+
+// Has determines if a queue has an given task at any priority.
+func (q *PriorityTaskQueue) Has(value any) bool {
+	bValue, err := q.codec.Marshal(value)
+	if err != nil {
+		return false
+	}
+	for _, key := range q.keys() {
+		count, err := q.Redis.LPosCount(q.ctx, key, string(bValue), 1, redis.LPosArgs{}).Result()
+		if err == nil && len(count) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds any number of tasks to the queue in order with the given priority. Items provided will
+// be marshaled with the queue's Codec.
+func (q *PriorityTaskQueue) Add(priority int, tasks ...any) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+	key, err := q.key(priority)
+	if err != nil {
+		return err
+	}
+	bTasks := make([]any, 0, len(tasks))
+	for _, task := range tasks {
+		bTask, err := q.codec.Marshal(task)
+		if err != nil {
+			return err
+		}
+		bTasks = append(bTasks, bTask)
+	}
+	added, err := q.Redis.RPush(q.ctx, key, bTasks...).Result()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return fmt.Errorf("failed to add tasks to queue")
+	}
+	return nil
+}
+
+// Pop removes the first task with the highest priority from the queue and unmarshals the value.
+func (q *PriorityTaskQueue) Pop(value any) error {
+	keys := q.keys()
+	result, err := priorityPopScript.Run(q.ctx, q.Redis, keys).Slice()
+	if err != nil {
+		if err == redis.Nil {
+			return ErrEmpty
+		}
+		return err
+	}
+	key := keys[result[0].(int64)]
+	popped := []byte(result[1].(string))
+	err = q.codec.Unmarshal(popped, value)
+	if err != nil {
+		if q.deadLetter != "" {
+			return deadLetter(q.ctx, q.Redis, q.deadLetter, popped, err)
+		}
+		if !q.noRetry {
+			added, err := q.Redis.RPush(q.ctx, key, popped).Result()
+			if err != nil {
+				return errors.Wrap(err, "failed to re-add task to queue after unmarshal failure")
+			}
+			if added == 0 {
+				return fmt.Errorf("failed to re-add task to queue after unmarshal failure")
+			}
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Remove removes a task from the queue at any priority.
+func (q *PriorityTaskQueue) Remove(task any) error {
+	bTask, err := q.codec.Marshal(task)
+	if err != nil {
+		return err
+	}
+	_, err = q.Redis.Pipelined(q.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range q.keys() {
+			pipe.LRem(q.ctx, key, 0, bTask)
+		}
+		return nil
+	})
+	return err
+}
+
+// Clear removes all tasks from the queue.
+func (q *PriorityTaskQueue) Clear() error {
+	_, err := q.Redis.Del(q.ctx, q.keys()...).Result()
+	return err
+}
+
+// key returns the Redis key of the list of tasks with the given priority.
+func (q *PriorityTaskQueue) key(priority int) (string, error) {
+	if priority < 0 || priority >= q.levels {
+		return "", fmt.Errorf("priority %d is not between 0 and %d", priority, q.levels-1)
+	}
+	return q.keyspace.priority(q.Name, priority), nil
+}
+
+// keys returns the Redis keys of every priority level's list of tasks, highest priority first.
+func (q *PriorityTaskQueue) keys() []string {
+	keys := make([]string, 0, q.levels)
+	for priority := q.levels - 1; priority >= 0; priority-- {
+		keys = append(keys, q.keyspace.priority(q.Name, priority))
+	}
+	return keys
+}
@@ -0,0 +1,135 @@
+package taskqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPriorityTaskQueue(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewPriority(name, ctx, addr, taskqueue.WithPriorityLevels(3))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	type Value struct {
+		String string `json:"string"`
+	}
+
+	t.Run("add", func(t *testing.T) {
+		require.NoError(t, queue.Add(0, Value{String: "low1"}, Value{String: "low2"}))
+		require.NoError(t, queue.Add(2, Value{String: "high1"}))
+		require.NoError(t, queue.Add(1, Value{String: "medium1"}))
+		require.NoError(t, queue.Add(2, Value{String: "high2"}))
+		assert.Equal(t, uint64(5), queue.Size())
+	})
+	t.Run("invalid priority", func(t *testing.T) {
+		require.Error(t, queue.Add(3, Value{String: "invalid"}))
+		require.Error(t, queue.Add(-1, Value{String: "invalid"}))
+	})
+	t.Run("unsupported options", func(t *testing.T) {
+		keyring, err := taskqueue.NewKeyring("1", map[string][]byte{"1": make([]byte, 32)})
+		require.NoError(t, err)
+		for _, option := range []taskqueue.Option{
+			taskqueue.WithEncryption(keyring),
+			taskqueue.WithCompression(taskqueue.CompressionGzip, 0),
+			taskqueue.WithEnvelope(),
+			taskqueue.WithMaxAttempts(3),
+			taskqueue.WithRateLimit(1, 1),
+			taskqueue.WithExpiredQueue(name + ":expired"),
+			taskqueue.WithVisibilityTimeout(time.Second),
+		} {
+			_, err := taskqueue.NewPriority(name, ctx, addr, option)
+			assert.Error(t, err)
+		}
+	})
+	t.Run("has", func(t *testing.T) {
+		assert.True(t, queue.Has(Value{String: "medium1"}))
+		assert.False(t, queue.Has(Value{String: "medium2"}))
+	})
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, queue.Remove(Value{String: "low2"}))
+		assert.Equal(t, uint64(4), queue.Size())
+	})
+	t.Run("pop in priority order", func(t *testing.T) {
+		expected := []string{"high1", "high2", "medium1", "low1"}
+		for _, e := range expected {
+			var value *Value
+			require.NoError(t, queue.Pop(&value))
+			assert.Equal(t, e, value.String)
+		}
+	})
+	t.Run("empty", func(t *testing.T) {
+		var value *Value
+		assert.ErrorIs(t, queue.Pop(&value), taskqueue.ErrEmpty)
+	})
+	t.Run("strings", func(t *testing.T) {
+		require.NoError(t, queue.Add(0, "low"))
+		require.NoError(t, queue.Add(1, "high"))
+		var value string
+		require.NoError(t, queue.Pop(&value))
+		assert.Equal(t, "high", value)
+		require.NoError(t, queue.Pop(&value))
+		assert.Equal(t, "low", value)
+	})
+	t.Run("raw strings", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		raw, err := taskqueue.NewPriority(name, ctx, addr, taskqueue.WithCodec(taskqueue.StringCodec{}))
+		require.NoError(t, err)
+		defer raw.Clear()
+		require.NoError(t, raw.Add(0, "low"))
+		require.NoError(t, raw.Add(9, "high"))
+		assert.True(t, raw.Has("low"))
+		var value string
+		require.NoError(t, raw.Pop(&value))
+		assert.Equal(t, "high", value)
+		require.NoError(t, raw.Pop(&value))
+		assert.Equal(t, "low", value)
+	})
+	t.Run("clear", func(t *testing.T) {
+		require.NoError(t, queue.Add(1, "value"))
+		require.NoError(t, queue.Clear())
+		assert.Equal(t, zero, queue.Size())
+	})
+}
+
+func TestPriorityTaskQueue_Retry(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewPriority(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	require.NoError(t, queue.Add(5, RetryValue{"value"}))
+	var popped *RetryValue
+	require.NoError(t, queue.Pop(&popped))
+	assert.Equal(t, uint64(1), queue.Size())
+	assert.True(t, queue.Has(RetryValue{"value"}))
+}
@@ -0,0 +1,112 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes up to ARGV[3] tokens from a token bucket, which is refilled at a rate of ARGV[1]
+// tokens per second up to a capacity of ARGV[2] tokens. If at least one token is available, as many
+// tokens as are available, up to ARGV[3], are taken, and the number taken is returned along with
+// zero. Otherwise, zero is returned along with the number of milliseconds until a token will be
+// available. The bucket is stored as a hash of the number of tokens and the time, in Unix
+// milliseconds, at which it was last updated.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+local bucket = redis.call("HMGET", KEYS[1], "tokens", "updated")
+local tokens = tonumber(bucket[1]) or burst
+local updated = tonumber(bucket[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate / 1000)
+if tokens < 1 then
+	return {0, math.ceil((1 - tokens) * 1000 / rate)}
+end
+local taken = math.min(math.floor(tokens), tonumber(ARGV[3]))
+redis.call("HSET", KEYS[1], "tokens", tostring(tokens - taken), "updated", now)
+redis.call("PEXPIRE", KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {taken, 0}
+`)
+
+// refundScript returns ARGV[1] tokens to a token bucket that still exists.
+var refundScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	redis.call("HINCRBYFLOAT", KEYS[1], "tokens", ARGV[1])
+end
+return 0
+`)
+
+// limited calls pop once a token has been taken from the queue's rate limiter, waiting until a
+// token is available, the deadline passes, or ctx is done. If ctx is done by the time a token is
+// taken, or pop doesn't return a task, the token is returned. If no rate limit is set, pop is called
+// immediately.
+func (s *store) limited(ctx context.Context, deadline time.Time, pop func() ([]byte, error)) ([]byte, error) {
+	if s.rate <= 0 {
+		return pop()
+	}
+	err := s.take(ctx, deadline)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		s.refund(1)
+		return nil, ctx.Err()
+	}
+	value, err := pop()
+	if err != nil {
+		s.refund(1)
+		return nil, err
+	}
+	return value, nil
+}
+
+// refund returns tokens to the queue's rate limiter.
+func (s *store) refund(tokens int) {
+	refundScript.Run(s.ctx, s.redis, []string{s.rateLimit}, tokens)
+}
+
+// take takes a token from the queue's rate limiter, waiting until a token is available, the
+// deadline passes, or ctx is done.
+func (s *store) take(ctx context.Context, deadline time.Time) error {
+	_, err := s.takeUpTo(ctx, deadline, 1)
+	return err
+}
+
+// takeUpTo takes as many tokens as are available from the queue's rate limiter, up to n, and
+// returns the number taken. If no token is available, it waits until one is, the deadline passes,
+// or ctx is done.
+func (s *store) takeUpTo(ctx context.Context, deadline time.Time, n int) (int, error) {
+	for {
+		result, err := takeScript.Run(ctx, s.redis, []string{s.rateLimit}, s.rate, s.burst, n).Int64Slice()
+		if err != nil {
+			return 0, err
+		}
+		taken, wait := result[0], result[1]
+		if taken > 0 {
+			return int(taken), nil
+		}
+		delay := time.Duration(wait) * time.Millisecond
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return 0, ErrTimeout
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// validRateLimit checks that a rate limit, if set, can be enforced.
+func validRateLimit(rate float64, burst int) error {
+	if rate > 0 && burst < 1 {
+		return fmt.Errorf("rate limit burst must be at least 1")
+	}
+	return nil
+}
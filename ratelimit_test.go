@@ -0,0 +1,105 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	t.Run("limits pop", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		limit := taskqueue.WithRateLimit(10, 2)
+		first, err := taskqueue.NewJSON(name, ctx, addr, limit)
+		require.NoError(t, err)
+		defer first.Clear()
+		second, err := taskqueue.NewJSON(name, ctx, addr, limit)
+		require.NoError(t, err)
+		require.NoError(t, first.Add(1, 2, 3, 4, 5, 6))
+
+		start := time.Now()
+		var value int
+		for i := 0; i < 3; i++ {
+			require.NoError(t, first.Pop(&value))
+			require.NoError(t, second.Pop(&value))
+		}
+		// The first two tasks are taken from the burst, and the other four are limited to one
+		// every 100 milliseconds.
+		assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*350)
+		assert.Equal(t, 6, value)
+	})
+
+	t.Run("empty pops are refunded", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithRateLimit(0.1, 1))
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		assert.Nil(t, queue.Pop())
+		_, err = queue.Reserve()
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+		require.NoError(t, queue.Add("value"))
+		start := time.Now()
+		value := queue.Pop()
+		require.NotNil(t, value)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("blocking pop times out", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithRateLimit(0.1, 1))
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("one", "two"))
+		value, err := queue.BlockingPop(context.Background(), time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "one", value)
+		_, err = queue.BlockingPop(context.Background(), time.Second)
+		assert.ErrorIs(t, err, taskqueue.ErrTimeout)
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+
+	t.Run("pop n takes available tokens", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithRateLimit(5, 5))
+		require.NoError(t, err)
+		defer queue.Clear()
+
+		require.NoError(t, queue.Add("one"))
+		start := time.Now()
+		values, err := queue.PopN(15)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"one"}, values)
+		assert.Less(t, time.Since(start), time.Millisecond*500)
+
+		// Only the popped task is charged, so the rest of the burst is still available.
+		require.NoError(t, queue.Add("two", "three", "four", "five", "six", "seven"))
+		start = time.Now()
+		values, err = queue.PopN(15)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, len(values), 4)
+		assert.LessOrEqual(t, len(values), 5)
+		assert.Less(t, time.Since(start), time.Millisecond*500)
+	})
+
+	t.Run("invalid burst", func(t *testing.T) {
+		_, err := taskqueue.NewBasic("name", ctx, addr, taskqueue.WithRateLimit(10, 0))
+		require.Error(t, err)
+	})
+}
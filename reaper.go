@@ -0,0 +1,96 @@
+package taskqueue
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// reapScript returns a task whose lease has expired to the end of its queue. The lease is checked
+// again so that a task acknowledged after it was found to be expired is not re-added.
+var reapScript = redis.NewScript(`
+local expires = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if not expires or tonumber(expires) > tonumber(ARGV[3]) then
+	return 0
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+if redis.call("LREM", KEYS[2], 1, ARGV[2]) == 0 then
+	return 0
+end
+redis.call("RPUSH", KEYS[3], ARGV[2])
+return 1
+`)
+
+// Reaper returns reserved tasks to their queue once their visibility timeout has expired, so tasks
+// held by a consumer that stopped without acknowledging them are eventually handled. Reaped tasks
+// are requeued as they were stored, so reaping doesn't count as a failed attempt.
+type Reaper struct {
+	// Name is the name of the queue to reap.
+	Name string
+	// Redis is the underlying Redis instance.
+	Redis    redis.UniversalClient
+	ctx      context.Context
+	keyspace keyspace
+}
+
+// NewReaper creates a new Reaper instance for the queue with the given name.
+func NewReaper(name string, option ...Option) (*Reaper, error) {
+	options, err := getOptions(option)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+	reaper := &Reaper{
+		Name:     name,
+		Redis:    redisClient,
+		ctx:      options.Context,
+		keyspace: newKeyspace(options, redisClient),
+	}
+	return reaper, nil
+}
+
+// Reap returns every task with an expired lease to the end of the queue and returns the number of
+// tasks that were requeued.
+func (r *Reaper) Reap() (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	leases := r.keyspace.leases(r.Name)
+	expired, err := r.Redis.ZRangeByScore(r.ctx, leases, &redis.ZRangeBy{Min: "-inf", Max: now}).Result()
+	if err != nil {
+		return 0, err
+	}
+	requeued := 0
+	for _, member := range expired {
+		consumer, raw, ok := parseLease(member)
+		if !ok {
+			r.Redis.ZRem(r.ctx, leases, member)
+			continue
+		}
+		keys := []string{leases, r.keyspace.processing(r.Name, consumer), r.keyspace.queue(r.Name)}
+		n, err := reapScript.Run(r.ctx, r.Redis, keys, member, raw, now).Int()
+		if err != nil {
+			return requeued, err
+		}
+		requeued += n
+	}
+	return requeued, nil
+}
+
+// Run calls Reap at every interval until ctx is done. Errors from individual passes are ignored so
+// that a temporary Redis failure doesn't stop the reaper; call Reap directly to handle them.
+func (r *Reaper) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		r.Reap()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
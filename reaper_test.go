@@ -0,0 +1,147 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReaper_Reap(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	visibility := taskqueue.WithVisibilityTimeout(time.Millisecond * 100)
+	queue, err := taskqueue.NewBasic(name, ctx, addr, visibility, taskqueue.WithConsumer("consumer"))
+	require.NoError(t, err)
+	reaper, err := taskqueue.NewReaper(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+		queue.Redis.Del(context.Background(), name+":processing:consumer", name+":leases")
+	})
+
+	t.Run("unexpired lease is kept", func(t *testing.T) {
+		err := queue.Add("one")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+		require.NoError(t, task.Ack())
+	})
+	t.Run("expired lease is requeued", func(t *testing.T) {
+		err := queue.Add("two")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 200)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+		assert.Equal(t, uint64(1), queue.Size())
+		assert.ErrorIs(t, task.Ack(), taskqueue.ErrNotHeld)
+		assert.Equal(t, "two", *queue.Pop())
+	})
+	t.Run("blocking reserve lease is requeued", func(t *testing.T) {
+		err := queue.Add("three")
+		require.NoError(t, err)
+		_, err = queue.BlockingReserve(context.Background(), time.Second)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 200)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+		assert.Equal(t, "three", *queue.Pop())
+	})
+	t.Run("acknowledged task is not requeued", func(t *testing.T) {
+		err := queue.Add("four")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, task.Ack())
+		time.Sleep(time.Millisecond * 200)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+		assert.Equal(t, zero, queue.Size())
+	})
+	t.Run("extended lease is kept", func(t *testing.T) {
+		err := queue.Add("six")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, task.Extend(time.Millisecond*500))
+		time.Sleep(time.Millisecond * 200)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+		require.NoError(t, task.Ack())
+		assert.ErrorIs(t, task.Extend(time.Second), taskqueue.ErrNotHeld)
+	})
+	t.Run("equal values have their own leases", func(t *testing.T) {
+		err := queue.Add("five", "five")
+		require.NoError(t, err)
+		first, err := queue.Reserve()
+		require.NoError(t, err)
+		_, err = queue.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, first.Ack())
+		time.Sleep(time.Millisecond * 200)
+		requeued, err := reaper.Reap()
+		require.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+		assert.Equal(t, "five", *queue.Pop())
+	})
+}
+
+func TestReaper_Run(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	visibility := taskqueue.WithVisibilityTimeout(time.Millisecond * 100)
+	queue, err := taskqueue.NewJSON(name, ctx, addr, visibility, taskqueue.WithConsumer("consumer"))
+	require.NoError(t, err)
+	reaper, err := taskqueue.NewReaper(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+		queue.Redis.Del(context.Background(), name+":processing:consumer", name+":leases")
+	})
+
+	err = queue.Add(map[string]string{"key": "value"})
+	require.NoError(t, err)
+	_, err = queue.Reserve()
+	require.NoError(t, err)
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*500)
+	defer cancel()
+	err = reaper.Run(c, time.Millisecond*50)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var value map[string]string
+	err = queue.Pop(&value)
+	require.NoError(t, err)
+	assert.Equal(t, "value", value["key"])
+}
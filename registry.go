@@ -0,0 +1,232 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QueueType is the type of a registered queue.
+type QueueType string
+
+const (
+	// QueueTypeBasic is the type of a queue created with NewBasic.
+	QueueTypeBasic QueueType = "basic"
+	// QueueTypeJSON is the type of a queue created with NewJSON or NewTyped.
+	QueueTypeJSON QueueType = "json"
+)
+
+// basicContentType is the content type recorded for queues of string values.
+const basicContentType = "text/plain"
+
+// QueueInfo describes a queue recorded in the registry.
+type QueueInfo struct {
+	// Name is the name the queue was created with.
+	Name string
+	// Type is the type of the queue.
+	Type QueueType
+	// ContentType is the content type of the queue's codec.
+	ContentType string
+	// CreatedAt is the time at which the queue was first created.
+	CreatedAt time.Time
+}
+
+// QueueStats describes a queue recorded in the registry, along with the number of tasks it holds.
+type QueueStats struct {
+	QueueInfo
+	// Size is the number of tasks waiting in the queue.
+	Size uint64
+	// Scheduled is the number of tasks added with AddAt or AddIn that are not yet due.
+	Scheduled uint64
+	// Processing is the number of tasks reserved by every consumer that haven't been acknowledged.
+	Processing uint64
+}
+
+// Registry discovers and manages the queues created with NewBasic, NewJSON, or NewTyped. Each
+// queue records itself in the registry when it's created, so the registry must use the same key
+// prefix as the queues it manages.
+type Registry struct {
+	// Redis is the underlying Redis instance.
+	Redis    redis.UniversalClient
+	ctx      context.Context
+	keyspace keyspace
+}
+
+// NewRegistry creates a new Registry instance.
+func NewRegistry(option ...Option) (*Registry, error) {
+	options, err := getOptions(option)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+	registry := &Registry{
+		Redis:    redisClient,
+		ctx:      options.Context,
+		keyspace: newKeyspace(options, redisClient),
+	}
+	return registry, nil
+}
+
+// ListQueues returns every registered queue, sorted by name.
+func (r *Registry) ListQueues() ([]QueueInfo, error) {
+	names, err := r.Redis.SMembers(r.ctx, r.keyspace.registry()).Result()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	cmds := make([]*redis.MapStringStringCmd, len(names))
+	_, err = r.Redis.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		for i, name := range names {
+			cmds[i] = pipe.HGetAll(r.ctx, r.keyspace.metadata(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	queues := make([]QueueInfo, 0, len(names))
+	for i, name := range names {
+		queues = append(queues, queueInfo(name, cmds[i].Val()))
+	}
+	return queues, nil
+}
+
+// Stats returns the registered queue with the given name, along with the number of tasks it holds.
+func (r *Registry) Stats(name string) (*QueueStats, error) {
+	metadata, err := r.Redis.HGetAll(r.ctx, r.keyspace.metadata(name)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(metadata) == 0 {
+		return nil, fmt.Errorf("queue '%s' is not registered", name)
+	}
+	processing, err := r.processing(name)
+	if err != nil {
+		return nil, err
+	}
+	var size, scheduled *redis.IntCmd
+	lengths := make([]*redis.IntCmd, len(processing))
+	_, err = r.Redis.Pipelined(r.ctx, func(pipe redis.Pipeliner) error {
+		size = pipe.LLen(r.ctx, r.keyspace.queue(name))
+		scheduled = pipe.ZCard(r.ctx, r.keyspace.scheduled(name))
+		for i, key := range processing {
+			lengths[i] = pipe.LLen(r.ctx, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := &QueueStats{
+		QueueInfo: queueInfo(name, metadata),
+		Size:      uint64(size.Val()),
+		Scheduled: uint64(scheduled.Val()),
+	}
+	for _, length := range lengths {
+		stats.Processing += uint64(length.Val())
+	}
+	return stats, nil
+}
+
+// Delete removes a queue from the registry, along with every task it holds, including scheduled
+// tasks, tasks reserved by any consumer, and tasks in its default dead-letter queue. Dead-letter and
+// expired queues set by name are left as they are.
+func (r *Registry) Delete(name string) error {
+	processing, err := r.processing(name)
+	if err != nil {
+		return err
+	}
+	keys := append([]string{
+		r.keyspace.queue(name),
+		r.keyspace.scheduled(name),
+		r.keyspace.leases(name),
+		r.keyspace.unique(name),
+		r.keyspace.rateLimit(name),
+		r.keyspace.deadLetter(name),
+		r.keyspace.metadata(name),
+	}, processing...)
+	err = r.Redis.Del(r.ctx, keys...).Err()
+	if err != nil {
+		return err
+	}
+	return r.Redis.SRem(r.ctx, r.keyspace.registry(), name).Err()
+}
+
+// processing returns the keys of every consumer's processing list for a queue.
+func (r *Registry) processing(name string) ([]string, error) {
+	pattern := escapePattern(r.keyspace.processing(name, "")) + "*"
+	return scanKeys(r.ctx, r.Redis, pattern)
+}
+
+// register records the queue in the registry. The time at which the queue was created is only
+// recorded the first time it's registered.
+func (s *store) register(queueType QueueType, contentType string) error {
+	key := s.keyspace.metadata(s.name)
+	_, err := s.redis.Pipelined(s.ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(s.ctx, key, "type", string(queueType), "content_type", contentType)
+		pipe.HSetNX(s.ctx, key, "created_at", time.Now().UTC().Format(time.RFC3339Nano))
+		pipe.SAdd(s.ctx, s.keyspace.registry(), s.name)
+		return nil
+	})
+	return err
+}
+
+// queueInfo creates a QueueInfo from a queue's metadata.
+func queueInfo(name string, metadata map[string]string) QueueInfo {
+	createdAt, _ := time.Parse(time.RFC3339Nano, metadata["created_at"])
+	return QueueInfo{
+		Name:        name,
+		Type:        QueueType(metadata["type"]),
+		ContentType: metadata["content_type"],
+		CreatedAt:   createdAt,
+	}
+}
+
+// scanKeys returns every key matching pattern, scanning each master node of a cluster.
+func scanKeys(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scan(ctx, client, pattern)
+	}
+	var mu sync.Mutex
+	var keys []string
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		found, err := scan(ctx, node, pattern)
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return err
+	})
+	return keys, err
+}
+
+// scan returns every key matching pattern on a single node.
+func scan(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+// escapePattern escapes the characters of key that have a special meaning in a Redis glob pattern.
+func escapePattern(key string) string {
+	var escaped strings.Builder
+	for _, r := range key {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			escaped.WriteRune('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
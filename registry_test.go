@@ -0,0 +1,118 @@
+package taskqueue_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	t.Run("list queues", func(t *testing.T) {
+		prefix := taskqueue.WithKeyPrefix(fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano)))
+		registry, err := taskqueue.NewRegistry(ctx, addr, prefix)
+		require.NoError(t, err)
+		start := time.Now()
+		_, err = taskqueue.NewBasic("basic", ctx, addr, prefix)
+		require.NoError(t, err)
+		_, err = taskqueue.NewJSON("json", ctx, addr, prefix, taskqueue.WithCodec(taskqueue.MsgpackCodec{}))
+		require.NoError(t, err)
+		defer registry.Delete("basic")
+		defer registry.Delete("json")
+
+		queues, err := registry.ListQueues()
+		require.NoError(t, err)
+		require.Len(t, queues, 2)
+		assert.Equal(t, "basic", queues[0].Name)
+		assert.Equal(t, taskqueue.QueueTypeBasic, queues[0].Type)
+		assert.Equal(t, "text/plain", queues[0].ContentType)
+		assert.WithinDuration(t, start, queues[0].CreatedAt, time.Second)
+		assert.Equal(t, "json", queues[1].Name)
+		assert.Equal(t, taskqueue.QueueTypeJSON, queues[1].Type)
+		assert.Equal(t, "application/msgpack", queues[1].ContentType)
+	})
+
+	t.Run("created time is kept", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		registry, err := taskqueue.NewRegistry(ctx, addr)
+		require.NoError(t, err)
+		_, err = taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer registry.Delete(name)
+		first, err := registry.Stats(name)
+		require.NoError(t, err)
+		_, err = taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		second, err := registry.Stats(name)
+		require.NoError(t, err)
+		assert.Equal(t, first.CreatedAt, second.CreatedAt)
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		registry, err := taskqueue.NewRegistry(ctx, addr)
+		require.NoError(t, err)
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer registry.Delete(name)
+		other, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithConsumer("other"))
+		require.NoError(t, err)
+
+		require.NoError(t, queue.Add("one", "two", "three", "four"))
+		require.NoError(t, queue.AddIn(time.Hour, "later"))
+		_, err = queue.Reserve()
+		require.NoError(t, err)
+		_, err = other.Reserve()
+		require.NoError(t, err)
+
+		stats, err := registry.Stats(name)
+		require.NoError(t, err)
+		assert.Equal(t, name, stats.Name)
+		assert.Equal(t, taskqueue.QueueTypeBasic, stats.Type)
+		assert.Equal(t, uint64(2), stats.Size)
+		assert.Equal(t, uint64(1), stats.Scheduled)
+		assert.Equal(t, uint64(2), stats.Processing)
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		registry, err := taskqueue.NewRegistry(ctx, addr)
+		require.NoError(t, err)
+		_, err = registry.Stats(name)
+		assert.Error(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		prefix := taskqueue.WithKeyPrefix(fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano)))
+		registry, err := taskqueue.NewRegistry(ctx, addr, prefix)
+		require.NoError(t, err)
+		queue, err := taskqueue.NewBasic("queue", ctx, addr, prefix)
+		require.NoError(t, err)
+		require.NoError(t, queue.Add("one", "two"))
+		require.NoError(t, queue.AddIn(time.Hour, "later"))
+		_, err = queue.Reserve()
+		require.NoError(t, err)
+
+		require.NoError(t, registry.Delete("queue"))
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, zero, queue.ScheduledSize())
+		_, err = registry.Stats("queue")
+		assert.Error(t, err)
+		queues, err := registry.ListQueues()
+		require.NoError(t, err)
+		assert.Empty(t, queues)
+	})
+}
@@ -0,0 +1,326 @@
+package taskqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// reserveScript moves the first task of a queue to a processing list and, if a visibility timeout
+// is set, records a lease that expires at the given time.
+var reserveScript = redis.NewScript(`
+local raw = redis.call("LMOVE", KEYS[1], KEYS[2], "LEFT", "RIGHT")
+if not raw then
+	return false
+end
+if tonumber(ARGV[2]) > 0 then
+	redis.call("ZADD", KEYS[3], ARGV[2], ARGV[1] .. raw)
+end
+return raw
+`)
+
+// ackScript removes a task and its lease from a processing list, releasing its key.
+var ackScript = redis.NewScript(uniqueLua + `
+redis.call("ZREM", KEYS[2], ARGV[2])
+local removed = redis.call("LREM", KEYS[1], 1, ARGV[1])
+if removed > 0 then
+	release(KEYS[3], ARGV[1])
+end
+return removed
+`)
+
+// extendScript sets a new expiry time for a lease, only if the lease still exists.
+var extendScript = redis.NewScript(`
+if not redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	return 0
+end
+redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+return 1
+`)
+
+// moveScript removes a task and its lease from a processing list and, only if the task was still
+// there, adds a value to the end of another list. This prevents a task from being re-added if it
+// was already acknowledged or reaped. If ARGV[4] is "1", the task's key is released; otherwise, it
+// is moved to the new value.
+var moveScript = redis.NewScript(uniqueLua + `
+redis.call("ZREM", KEYS[2], ARGV[2])
+if redis.call("LREM", KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+if ARGV[4] == "1" then
+	release(KEYS[4], ARGV[1])
+else
+	remap(KEYS[4], ARGV[1], ARGV[3])
+end
+redis.call("RPUSH", KEYS[3], ARGV[3])
+return 1
+`)
+
+// ErrMaxAttempts is recorded as the cause of a task that was dead-lettered after reaching its
+// maximum number of attempts without a more specific error.
+var ErrMaxAttempts = errors.New("task reached its maximum number of attempts")
+
+// Task is a task reserved from a queue. A reserved task is held in the consumer's processing list
+// until it is acknowledged with Ack or returned to the queue with Nack, so a task held by a
+// consumer that crashes is not lost.
+type Task struct {
+	store    *store
+	raw      []byte
+	lease    string
+	payload  []byte
+	envelope *Envelope
+	decode   func(data []byte, value any) error
+	err      error
+}
+
+// Bytes returns the raw task value.
+func (t *Task) Bytes() []byte {
+	return t.payload
+}
+
+// String returns the task value as a string.
+func (t *Task) String() string {
+	return string(t.payload)
+}
+
+// Decode unmarshals the task value into value, the same way the queue the task was reserved from
+// would when popping it. If the stored value couldn't be read, the error is returned.
+func (t *Task) Decode(value any) error {
+	if t.err != nil {
+		return t.err
+	}
+	return t.decode(t.payload, value)
+}
+
+// Queue returns the name of the queue the task was reserved from.
+func (t *Task) Queue() string {
+	return t.store.name
+}
+
+// Envelope returns the envelope the task was stored in, or nil if it was stored without one.
+func (t *Task) Envelope() *Envelope {
+	return t.envelope
+}
+
+// Ack acknowledges the task, permanently removing it from the consumer's processing list.
+func (t *Task) Ack() error {
+	s := t.store
+	keys := []string{s.processing, s.leases, s.unique}
+	removed, err := ackScript.Run(s.ctx, s.redis, keys, t.raw, t.lease).Int()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Extend renews the task's lease so that it expires after timeout instead of the queue's visibility
+// timeout, which prevents a Reaper from returning a task that is still being handled to the queue.
+// If the lease has already expired and been reaped, ErrNotHeld is returned. If no visibility timeout
+// is set, the task has no lease, and Extend does nothing.
+func (t *Task) Extend(timeout time.Duration) error {
+	s := t.store
+	if s.visibility <= 0 {
+		return nil
+	}
+	deadline := time.Now().Add(timeout).UnixMilli()
+	extended, err := extendScript.Run(s.ctx, s.redis, []string{s.leases}, t.lease, deadline).Int()
+	if err != nil {
+		return err
+	}
+	if extended == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Nack returns the task to the end of the queue it was reserved from. If the task has an envelope,
+// the attempt is counted, and a task that has reached its maximum number of attempts is moved to
+// the dead-letter queue instead.
+func (t *Task) Nack() error {
+	return t.requeue(nil)
+}
+
+// Fail records a failed attempt to handle the task. The task is returned to the queue the same way
+// as Nack, with cause recorded as its last error. If retries are disabled, the task is moved to the
+// dead-letter queue if one is set, and otherwise removed.
+func (t *Task) Fail(cause error) error {
+	if t.store.noRetry {
+		if t.store.deadLetterKey() != "" {
+			return t.DeadLetter(cause)
+		}
+		return t.Ack()
+	}
+	return t.requeue(cause)
+}
+
+// DeadLetter moves the task to the dead-letter queue along with cause.
+func (t *Task) DeadLetter(cause error) error {
+	return t.deadLetter(t.raw, cause)
+}
+
+func (t *Task) deadLetter(value []byte, cause error) error {
+	key := t.store.deadLetterKey()
+	if key == "" {
+		return fmt.Errorf("no dead-letter queue is set")
+	}
+	letter, err := newDeadLetter(value, cause)
+	if err != nil {
+		return err
+	}
+	return t.move(key, letter, true)
+}
+
+func (t *Task) requeue(cause error) error {
+	exhausted := t.store.exhausted(t.envelope, cause)
+	value := t.raw
+	if t.envelope != nil {
+		var err error
+		value, err = t.store.reseal(t.envelope)
+		if err != nil {
+			return err
+		}
+	}
+	if exhausted {
+		if cause == nil {
+			cause = ErrMaxAttempts
+		}
+		return t.deadLetter(value, cause)
+	}
+	return t.move(t.store.queue, value, false)
+}
+
+// move removes the task from the processing list and adds value to the end of the list at key. The
+// task's key is released if release is set, and otherwise moved to value.
+func (t *Task) move(key string, value []byte, release bool) error {
+	s := t.store
+	keys := []string{s.processing, s.leases, key, s.unique}
+	flag := "0"
+	if release {
+		flag = "1"
+	}
+	moved, err := moveScript.Run(s.ctx, s.redis, keys, t.raw, t.lease, value, flag).Int()
+	if err != nil {
+		return err
+	}
+	if moved == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// task creates a Task from a value reserved from the queue under lease.
+func (s *store) task(raw []byte, lease string, decode func(data []byte, value any) error) *Task {
+	payload, env, err := s.open(raw)
+	return &Task{store: s, raw: raw, lease: lease, payload: payload, envelope: env, decode: decode, err: err}
+}
+
+// reserve moves the first task of the queue that hasn't expired to the processing list, and returns
+// it along with its lease. Waiting for the rate limit stops once ctx is done.
+func (s *store) reserve(ctx context.Context) ([]byte, string, error) {
+	_, err := s.promote()
+	if err != nil {
+		return nil, "", err
+	}
+	var lease string
+	raw, err := s.limited(ctx, time.Time{}, func() ([]byte, error) {
+		for {
+			prefix, err := s.leasePrefix()
+			if err != nil {
+				return nil, err
+			}
+			keys := []string{s.queue, s.processing, s.leases}
+			raw, err := reserveScript.Run(s.ctx, s.redis, keys, prefix, s.deadline()).Text()
+			if err != nil {
+				if err == redis.Nil {
+					return nil, ErrEmpty
+				}
+				return nil, err
+			}
+			lease = prefix + raw
+			expired, err := s.expire([]byte(raw), lease)
+			if err != nil {
+				return nil, err
+			}
+			if !expired {
+				return []byte(raw), nil
+			}
+		}
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return raw, lease, nil
+}
+
+// blockingReserve moves the first task of the queue to the processing list, waiting for a task to
+// be added if the queue is empty, and returns it along with its lease. Scripts can't block, so
+// unlike reserve, the task is moved and its lease recorded in separate commands.
+func (s *store) blockingReserve(ctx context.Context, timeout time.Duration) ([]byte, string, error) {
+	raw, lease, err := s.blockingMove(ctx, timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.visibility > 0 {
+		member := redis.Z{Score: float64(s.deadline()), Member: lease}
+		err = s.redis.ZAdd(s.ctx, s.leases, member).Err()
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	return raw, lease, nil
+}
+
+// deadline returns the time, in Unix milliseconds, at which a task reserved now becomes visible
+// again, or zero if no visibility timeout is set.
+func (s *store) deadline() int64 {
+	if s.visibility <= 0 {
+		return 0
+	}
+	return time.Now().Add(s.visibility).UnixMilli()
+}
+
+// leasePrefix returns the start of the member of the leases sorted set that tracks a reservation,
+// which is followed by the reserved value. Each reservation has a random nonce, so that equal values
+// reserved by the same consumer have their own leases.
+func (s *store) leasePrefix() (string, error) {
+	nonce := make([]byte, leaseNonceLength/2)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	return s.consumer + "\x00" + hex.EncodeToString(nonce) + "\x00", nil
+}
+
+// leaseNonceLength is the length of the hex-encoded nonce in each lease.
+const leaseNonceLength = 16
+
+// parseLease returns the consumer and reserved value of a member of the leases sorted set.
+func parseLease(member string) (consumer, raw string, ok bool) {
+	consumer, rest, ok := strings.Cut(member, "\x00")
+	if !ok || len(rest) < leaseNonceLength+1 || rest[leaseNonceLength] != 0 {
+		return "", "", false
+	}
+	return consumer, rest[leaseNonceLength+1:], true
+}
+
+// decodeString decodes a task value into a string or byte slice pointer.
+func decodeString(data []byte, value any) error {
+	switch v := value.(type) {
+	case *string:
+		*v = string(data)
+	case *[]byte:
+		*v = data
+	default:
+		return fmt.Errorf("unable to decode task into %T", value)
+	}
+	return nil
+}
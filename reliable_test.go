@@ -0,0 +1,152 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_Reserve(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithConsumer("consumer"))
+	require.NoError(t, err)
+	processing := name + ":processing:consumer"
+	t.Cleanup(func() {
+		queue.Clear()
+		queue.Redis.Del(context.Background(), processing)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := queue.Reserve()
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+	})
+	t.Run("ack", func(t *testing.T) {
+		err := queue.Add("one")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		assert.Equal(t, "one", task.String())
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, int64(1), queue.Redis.LLen(context.Background(), processing).Val())
+		err = task.Ack()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), queue.Redis.LLen(context.Background(), processing).Val())
+		assert.ErrorIs(t, task.Ack(), taskqueue.ErrNotHeld)
+	})
+	t.Run("nack", func(t *testing.T) {
+		err := queue.Add("two")
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		var value string
+		err = task.Decode(&value)
+		require.NoError(t, err)
+		assert.Equal(t, "two", value)
+		err = task.Nack()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+		assert.Equal(t, int64(0), queue.Redis.LLen(context.Background(), processing).Val())
+		assert.ErrorIs(t, task.Nack(), taskqueue.ErrNotHeld)
+		assert.Equal(t, uint64(1), queue.Size())
+		assert.Equal(t, "two", *queue.Pop())
+	})
+	t.Run("blocking", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			queue.Add("three")
+		}()
+		task, err := queue.BlockingReserve(context.Background(), time.Second*5)
+		require.NoError(t, err)
+		assert.Equal(t, "three", task.String())
+		require.NoError(t, task.Ack())
+	})
+	t.Run("blocking timeout", func(t *testing.T) {
+		_, err := queue.BlockingReserve(context.Background(), time.Second)
+		assert.ErrorIs(t, err, taskqueue.ErrTimeout)
+	})
+}
+
+func TestJSONTaskQueue_Reserve(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithConsumer("consumer"))
+	require.NoError(t, err)
+	processing := name + ":processing:consumer"
+	t.Cleanup(func() {
+		queue.Clear()
+		queue.Redis.Del(context.Background(), processing)
+	})
+
+	type Value struct {
+		String string `json:"string"`
+		Number int    `json:"number"`
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		_, err := queue.Reserve()
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+	})
+	t.Run("ack", func(t *testing.T) {
+		err := queue.Add(Value{String: "one", Number: 1})
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		var value *Value
+		err = task.Decode(&value)
+		require.NoError(t, err)
+		assert.Equal(t, "one", value.String)
+		assert.Equal(t, zero, queue.Size())
+		err = task.Ack()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), queue.Redis.LLen(context.Background(), processing).Val())
+	})
+	t.Run("nack", func(t *testing.T) {
+		err := queue.Add(Value{String: "two", Number: 2})
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		err = task.Nack()
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+		var value *Value
+		err = queue.Pop(&value)
+		require.NoError(t, err)
+		assert.Equal(t, 2, value.Number)
+	})
+	t.Run("blocking", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			queue.Add(Value{String: "three", Number: 3})
+		}()
+		task, err := queue.BlockingReserve(context.Background(), time.Second*5)
+		require.NoError(t, err)
+		var value *Value
+		require.NoError(t, task.Decode(&value))
+		assert.Equal(t, 3, value.Number)
+		require.NoError(t, task.Ack())
+	})
+}
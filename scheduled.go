@@ -0,0 +1,74 @@
+package taskqueue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// scheduledIDLength is the length of the random ID that begins each member of the scheduled
+// tasks sorted set. Without it, identical tasks scheduled more than once would be stored as one.
+const scheduledIDLength = 16
+
+// promoteBatchSize is the largest number of due tasks promoted by a single script call.
+const promoteBatchSize = 100
+
+// promoteScript moves up to a batch of due tasks from the scheduled tasks sorted set to the end of
+// the queue, in the order they became due, and returns the number of tasks moved.
+var promoteScript = redis.NewScript(`
+local due = redis.call("ZRANGEBYSCORE", KEYS[1], "-inf", ARGV[1], "LIMIT", 0, ARGV[2])
+for _, member in ipairs(due) do
+	redis.call("ZREM", KEYS[1], member)
+	redis.call("RPUSH", KEYS[2], string.sub(member, ARGV[3] + 1))
+end
+return #due
+`)
+
+// schedule adds stored values to the scheduled tasks sorted set, to be added to the queue at the
+// given time.
+func (s *store) schedule(at time.Time, values [][]byte) error {
+	if len(values) == 0 {
+		return nil
+	}
+	members := make([]redis.Z, 0, len(values))
+	for _, value := range values {
+		id := make([]byte, scheduledIDLength/2)
+		_, err := rand.Read(id)
+		if err != nil {
+			return err
+		}
+		member := append([]byte(hex.EncodeToString(id)), value...)
+		members = append(members, redis.Z{Score: float64(at.UnixMilli()), Member: member})
+	}
+	return s.redis.ZAdd(s.ctx, s.scheduled, members...).Err()
+}
+
+// promote moves every due scheduled task to the end of the queue and returns the number of tasks
+// moved.
+func (s *store) promote() (int, error) {
+	keys := []string{s.scheduled, s.queue}
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	promoted := 0
+	for {
+		n, err := promoteScript.Run(s.ctx, s.redis, keys, now, promoteBatchSize, scheduledIDLength).Int()
+		if err != nil {
+			return promoted, err
+		}
+		promoted += n
+		if n < promoteBatchSize {
+			return promoted, nil
+		}
+	}
+}
+
+// scheduledSize returns the number of scheduled tasks that have not yet been added to the queue.
+func (s *store) scheduledSize() uint64 {
+	size, err := s.redis.ZCard(s.ctx, s.scheduled).Uint64()
+	if err != nil {
+		return 0
+	}
+	return size
+}
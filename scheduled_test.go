@@ -0,0 +1,125 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_AddIn(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	t.Run("add", func(t *testing.T) {
+		err := queue.AddIn(time.Millisecond*100, "one", "one", "two")
+		require.NoError(t, err)
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, uint64(3), queue.ScheduledSize())
+	})
+	t.Run("not due", func(t *testing.T) {
+		assert.Nil(t, queue.Pop())
+	})
+	t.Run("due", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 150)
+		promoted, err := queue.Promote()
+		require.NoError(t, err)
+		assert.Equal(t, 3, promoted)
+		assert.Equal(t, uint64(3), queue.Size())
+		assert.Equal(t, zero, queue.ScheduledSize())
+		values := []string{*queue.Pop(), *queue.Pop(), *queue.Pop()}
+		assert.ElementsMatch(t, []string{"one", "one", "two"}, values)
+	})
+	t.Run("pop promotes", func(t *testing.T) {
+		err := queue.AddAt(time.Now().Add(-time.Second), "three")
+		require.NoError(t, err)
+		assert.Equal(t, "three", *queue.Pop())
+	})
+	t.Run("blocking pop promotes", func(t *testing.T) {
+		err := queue.AddIn(time.Millisecond*1500, "four")
+		require.NoError(t, err)
+		value, err := queue.BlockingPop(context.Background(), time.Second*5)
+		require.NoError(t, err)
+		assert.Equal(t, "four", value)
+	})
+	t.Run("clear", func(t *testing.T) {
+		err := queue.AddIn(time.Hour, "five")
+		require.NoError(t, err)
+		require.NoError(t, queue.Clear())
+		assert.Equal(t, zero, queue.ScheduledSize())
+	})
+}
+
+func TestJSONTaskQueue_AddAt(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithEnvelope())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	type Value struct {
+		String string `json:"string"`
+	}
+
+	t.Run("add", func(t *testing.T) {
+		now := time.Now()
+		err := queue.AddAt(now.Add(time.Millisecond*200), Value{String: "later"})
+		require.NoError(t, err)
+		err = queue.AddAt(now.Add(time.Millisecond*100), Value{String: "sooner"})
+		require.NoError(t, err)
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, uint64(2), queue.ScheduledSize())
+	})
+	t.Run("not due", func(t *testing.T) {
+		var value *Value
+		err := queue.Pop(&value)
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+	})
+	t.Run("due in order", func(t *testing.T) {
+		time.Sleep(time.Millisecond * 250)
+		var first, second *Value
+		require.NoError(t, queue.Pop(&first))
+		require.NoError(t, queue.Pop(&second))
+		assert.Equal(t, "sooner", first.String)
+		assert.Equal(t, "later", second.String)
+	})
+	t.Run("reserve promotes", func(t *testing.T) {
+		err := queue.AddIn(-time.Second, Value{String: "reserved"})
+		require.NoError(t, err)
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		var value *Value
+		require.NoError(t, task.Decode(&value))
+		assert.Equal(t, "reserved", value.String)
+		require.NoError(t, task.Ack())
+	})
+}
@@ -0,0 +1,166 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// Enqueuer is implemented by every queue type that tasks can be added to.
+type Enqueuer interface {
+	Add(tasks ...any) error
+}
+
+// Schedule determines when a recurring task is enqueued.
+type Schedule interface {
+	// Next returns the first time after t at which the task should be enqueued.
+	Next(t time.Time) time.Time
+}
+
+// everySchedule is a Schedule that fires at every multiple of an interval since the zero time, so
+// that replicas started at different times agree on when it fires.
+type everySchedule time.Duration
+
+func (e everySchedule) Next(t time.Time) time.Time {
+	interval := time.Duration(e)
+	return t.Truncate(interval).Add(interval)
+}
+
+// schedulerEntry is a recurring task registered with a Scheduler.
+type schedulerEntry struct {
+	name     string
+	schedule Schedule
+	queue    Enqueuer
+	tasks    []any
+	next     time.Time
+}
+
+// Scheduler adds recurring tasks to queues on a schedule. Any number of replicas of a scheduler
+// may run at once; a Redis lock ensures that each scheduled run is enqueued by only one of them.
+type Scheduler struct {
+	// Redis is the underlying Redis instance, used to coordinate replicas.
+	Redis    redis.UniversalClient
+	ctx      context.Context
+	keyspace keyspace
+	location *time.Location
+	entries  []*schedulerEntry
+}
+
+// NewScheduler creates a new Scheduler instance. Cron expressions are evaluated in the location set
+// with WithLocation, or in UTC by default.
+func NewScheduler(option ...Option) (*Scheduler, error) {
+	options, err := getOptions(option)
+	if err != nil {
+		return nil, err
+	}
+	redisClient, err := newRedisClient(options)
+	if err != nil {
+		return nil, err
+	}
+	location := options.Location
+	if location == nil {
+		location = time.UTC
+	}
+	scheduler := &Scheduler{
+		Redis:    redisClient,
+		ctx:      options.Context,
+		keyspace: newKeyspace(options, redisClient),
+		location: location,
+	}
+	return scheduler, nil
+}
+
+// Cron registers tasks to be added to queue on the schedule described by a standard five-field
+// cron expression, such as "30 * * * *", or a descriptor, such as "@daily". The expression may be
+// prefixed with "CRON_TZ=" and a location name to override the scheduler's location. The name
+// identifies the recurring task across replicas and must be unique.
+func (s *Scheduler) Cron(name, spec string, queue Enqueuer, tasks ...any) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return errors.Wrapf(err, "invalid cron expression '%s'", spec)
+	}
+	return s.Register(name, schedule, queue, tasks...)
+}
+
+// Every registers tasks to be added to queue at every interval. Runs are aligned to multiples of
+// the interval, so that every replica agrees on when they occur. The name identifies the recurring
+// task across replicas and must be unique.
+func (s *Scheduler) Every(name string, interval time.Duration, queue Enqueuer, tasks ...any) error {
+	if interval <= 0 {
+		return fmt.Errorf("interval must be greater than zero")
+	}
+	return s.Register(name, everySchedule(interval), queue, tasks...)
+}
+
+// Register registers tasks to be added to queue on a custom schedule. The name identifies the
+// recurring task across replicas and must be unique. Tasks must be registered before Run is called.
+func (s *Scheduler) Register(name string, schedule Schedule, queue Enqueuer, tasks ...any) error {
+	for _, entry := range s.entries {
+		if entry.name == name {
+			return fmt.Errorf("a recurring task named '%s' is already registered", name)
+		}
+	}
+	entry := &schedulerEntry{name: name, schedule: schedule, queue: queue, tasks: tasks}
+	s.entries = append(s.entries, entry)
+	return nil
+}
+
+// Run enqueues registered tasks as they become due until ctx is done. Runs missed while the
+// scheduler wasn't running are skipped. Errors from individual runs are ignored so that a temporary
+// Redis failure doesn't stop the scheduler.
+func (s *Scheduler) Run(ctx context.Context) error {
+	now := time.Now().In(s.location)
+	for _, entry := range s.entries {
+		entry.next = entry.schedule.Next(now)
+	}
+	for {
+		var next time.Time
+		for _, entry := range s.entries {
+			if next.IsZero() || entry.next.Before(next) {
+				next = entry.next
+			}
+		}
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			wake = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return ctx.Err()
+		case <-wake:
+		}
+		now := time.Now().In(s.location)
+		for _, entry := range s.entries {
+			if entry.next.After(now) {
+				continue
+			}
+			s.fire(entry, entry.next)
+			entry.next = entry.schedule.Next(now)
+		}
+	}
+}
+
+// fire adds an entry's tasks to its queue for the run at tick, unless another replica has already
+// done so.
+func (s *Scheduler) fire(entry *schedulerEntry, tick time.Time) error {
+	// The lock only needs to outlive the run it's for, and must not outlive the interval between
+	// runs. Redis doesn't accept expiry times shorter than a millisecond.
+	ttl := entry.schedule.Next(tick).Sub(tick)
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	acquired, err := s.Redis.SetNX(s.ctx, s.keyspace.schedulerLock(entry.name, tick), 1, ttl).Result()
+	if err != nil || !acquired {
+		return err
+	}
+	return entry.queue.Add(entry.tasks...)
+}
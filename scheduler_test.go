@@ -0,0 +1,144 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_Register(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	location, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	scheduler, err := taskqueue.NewScheduler(ctx, addr, taskqueue.WithLocation(location))
+	require.NoError(t, err)
+
+	t.Run("cron", func(t *testing.T) {
+		err := scheduler.Cron(name+"-cron", "30 9 * * 1-5", queue, "task")
+		require.NoError(t, err)
+	})
+	t.Run("cron with time zone", func(t *testing.T) {
+		err := scheduler.Cron(name+"-cron-tz", "CRON_TZ=Europe/London @daily", queue, "task")
+		require.NoError(t, err)
+	})
+	t.Run("invalid cron", func(t *testing.T) {
+		err := scheduler.Cron(name+"-invalid", "not a cron expression", queue, "task")
+		require.Error(t, err)
+	})
+	t.Run("invalid interval", func(t *testing.T) {
+		err := scheduler.Every(name+"-every", 0, queue, "task")
+		require.Error(t, err)
+	})
+	t.Run("duplicate name", func(t *testing.T) {
+		err := scheduler.Every(name+"-cron", time.Minute, queue, "task")
+		require.Error(t, err)
+	})
+}
+
+func TestScheduler_Run(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	// Two replicas of the same scheduler should enqueue each run only once.
+	replicas := make([]*taskqueue.Scheduler, 2)
+	for i := range replicas {
+		scheduler, err := taskqueue.NewScheduler(ctx, addr)
+		require.NoError(t, err)
+		err = scheduler.Every(name, time.Second, queue, "task")
+		require.NoError(t, err)
+		replicas[i] = scheduler
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*2500)
+	defer cancel()
+	done := make(chan error, len(replicas))
+	for _, scheduler := range replicas {
+		go func(scheduler *taskqueue.Scheduler) {
+			done <- scheduler.Run(c)
+		}(scheduler)
+	}
+	for range replicas {
+		assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+	}
+
+	size := queue.Size()
+	assert.GreaterOrEqual(t, size, uint64(2))
+	assert.LessOrEqual(t, size, uint64(3))
+	assert.Equal(t, "task", *queue.Pop())
+}
+
+func TestScheduler_RunShortInterval(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	// Runs less than a second apart should each be enqueued once.
+	replicas := make([]*taskqueue.Scheduler, 2)
+	for i := range replicas {
+		scheduler, err := taskqueue.NewScheduler(ctx, addr)
+		require.NoError(t, err)
+		err = scheduler.Every(name, time.Millisecond*250, queue, "task")
+		require.NoError(t, err)
+		replicas[i] = scheduler
+	}
+
+	c, cancel := context.WithTimeout(context.Background(), time.Millisecond*2100)
+	defer cancel()
+	done := make(chan error, len(replicas))
+	for _, scheduler := range replicas {
+		go func(scheduler *taskqueue.Scheduler) {
+			done <- scheduler.Run(c)
+		}(scheduler)
+	}
+	for range replicas {
+		assert.ErrorIs(t, <-done, context.DeadlineExceeded)
+	}
+
+	size := queue.Size()
+	assert.GreaterOrEqual(t, size, uint64(7))
+	assert.LessOrEqual(t, size, uint64(9))
+}
@@ -0,0 +1,332 @@
+package taskqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// removeIndexScript removes and returns the value at an index of a list, releasing its key. The
+// value is replaced with a unique marker, which is then removed, so that only the value at the index
+// is removed even if the list holds other equal values.
+var removeIndexScript = redis.NewScript(uniqueLua + `
+local value = redis.call("LINDEX", KEYS[1], ARGV[1])
+if not value then
+	return false
+end
+redis.call("LSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("LREM", KEYS[1], 1, ARGV[2])
+release(KEYS[2], value)
+return value
+`)
+
+// popScript removes and returns the first value of a list, releasing its key.
+var popScript = redis.NewScript(uniqueLua + `
+local value = redis.call("LPOP", KEYS[1])
+if value then
+	release(KEYS[2], value)
+end
+return value
+`)
+
+// requeueScript adds a value to the end of a list. If a key is given, it is claimed for the value
+// unless another task has claimed it since.
+var requeueScript = redis.NewScript(uniqueLua + `
+if ARGV[2] ~= "" then
+	claim(KEYS[2], ARGV[2], ARGV[1])
+end
+return redis.call("RPUSH", KEYS[1], ARGV[1])
+`)
+
+// store holds the configuration shared by every queue type and the tasks reserved from them.
+type store struct {
+	redis       redis.UniversalClient
+	ctx         context.Context
+	keyspace    keyspace
+	name        string
+	queue       string
+	processing  string
+	leases      string
+	scheduled   string
+	consumer    string
+	visibility  time.Duration
+	noRetry     bool
+	deadLetter  string
+	maxAttempts int
+	envelope    bool
+	compression Compression
+	threshold   int
+	keyring     *Keyring
+	rateLimit   string
+	unique      string
+	expired     string
+	rate        float64
+	burst       int
+}
+
+func newStore(redisClient redis.UniversalClient, name string, options *Options) *store {
+	keys := newKeyspace(options, redisClient)
+	return &store{
+		redis:       redisClient,
+		ctx:         options.Context,
+		keyspace:    keys,
+		name:        name,
+		queue:       keys.queue(name),
+		processing:  keys.processing(name, options.Consumer),
+		leases:      keys.leases(name),
+		scheduled:   keys.scheduled(name),
+		consumer:    options.Consumer,
+		visibility:  options.VisibilityTimeout,
+		noRetry:     options.NoRetry,
+		deadLetter:  keys.owned(name, "dead-letter", options.DeadLetterQueue),
+		maxAttempts: options.MaxAttempts,
+		envelope:    options.Envelope || options.MaxAttempts > 0,
+		compression: options.Compression,
+		threshold:   options.CompressionLimit,
+		keyring:     options.Keyring,
+		rateLimit:   keys.rateLimit(name),
+		unique:      keys.unique(name),
+		expired:     keys.owned(name, "expired", options.ExpiredQueue),
+		rate:        options.RateLimit,
+		burst:       options.RateLimitBurst,
+	}
+}
+
+// removeIndex removes and returns the stored value at an index of the queue, releasing its key.
+func (s *store) removeIndex(index int64) ([]byte, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, err
+	}
+	marker := "\x00taskqueue:removed:" + hex.EncodeToString(id)
+	value, err := removeIndexScript.Run(s.ctx, s.redis, []string{s.queue, s.unique}, index, marker).Text()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, fmt.Errorf("index %d does not exist in queue", index)
+		}
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// contains determines if the queue has a task value stored without an envelope. If encryption is
+// enabled, the value is looked for as encrypted by each key in the keyring.
+func (s *store) contains(payload []byte) (bool, error) {
+	values, err := s.packAll(payload)
+	if err != nil {
+		return false, err
+	}
+	for _, value := range values {
+		_, err := s.redis.LPos(s.ctx, s.queue, string(value), redis.LPosArgs{}).Result()
+		if err == nil {
+			return true, nil
+		}
+		if err != redis.Nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// removeValue removes every task value stored without an envelope that is equal to payload. If
+// encryption is enabled, the value is removed as encrypted by each key in the keyring.
+func (s *store) removeValue(payload []byte) error {
+	values, err := s.packAll(payload)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		err = s.redis.LRem(s.ctx, s.queue, 0, value).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// has determines if the queue has a task whose task value is equal to payload. Unless envelopes are
+// enabled, the stored value is looked for by Redis first. Envelopes differ from their task values,
+// so they're only read and compared if envelopes are enabled or tasks added with a key are pending.
+func (s *store) has(payload []byte) (bool, error) {
+	if !s.envelope {
+		found, err := s.contains(payload)
+		if err != nil || found || !s.hasUnique() {
+			return found, err
+		}
+	}
+	matches, err := s.match(payload)
+	if err != nil {
+		return false, err
+	}
+	return len(matches) > 0, nil
+}
+
+// remove removes every task whose task value is equal to payload, releasing their keys. Stored
+// values are removed by Redis the same way has looks for them, and envelopes are only read and
+// compared if envelopes are enabled or tasks added with a key are pending.
+func (s *store) remove(payload []byte) error {
+	if !s.envelope {
+		err := s.removeValue(payload)
+		if err != nil || !s.hasUnique() {
+			return err
+		}
+	}
+	matches, err := s.match(payload)
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		err = removeScript.Run(s.ctx, s.redis, []string{s.queue, s.unique}, match).Err()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pop removes and returns the first value of the queue that hasn't expired. If the queue is empty,
+// ErrEmpty is returned.
+func (s *store) pop() ([]byte, error) {
+	popped, err := s.lpop()
+	if err == redis.Nil {
+		return nil, ErrEmpty
+	}
+	return popped, err
+}
+
+// lpop is the same as pop, but returns redis.Nil if the queue is empty.
+func (s *store) lpop() ([]byte, error) {
+	_, err := s.promote()
+	if err != nil {
+		return nil, err
+	}
+	return s.limited(s.ctx, time.Time{}, func() ([]byte, error) {
+		for {
+			popped, err := s.popOne(s.ctx)
+			if err != nil {
+				return nil, err
+			}
+			expired, err := s.expire(popped, "")
+			if err != nil {
+				return nil, err
+			}
+			if !expired {
+				return popped, nil
+			}
+		}
+	})
+}
+
+// popOne removes and returns the first value of the queue, releasing its key. If the queue is
+// empty, redis.Nil is returned.
+func (s *store) popOne(ctx context.Context) ([]byte, error) {
+	value, err := popScript.Run(ctx, s.redis, []string{s.queue, s.unique}).Text()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// transforms determines if task values are stored differently than they were added, rather than
+// as-is.
+func (s *store) transforms() bool {
+	return s.envelope || s.compression != "" || s.keyring != nil
+}
+
+// pack returns the stored form of a value, which is compressed and then encrypted if enabled.
+func (s *store) pack(value []byte) ([]byte, error) {
+	value, err := s.compress(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.encrypt(value), nil
+}
+
+// packAll returns every stored form a value may have been given by pack, one for each key in the
+// keyring if encryption is enabled.
+func (s *store) packAll(value []byte) ([][]byte, error) {
+	value, err := s.compress(value)
+	if err != nil {
+		return nil, err
+	}
+	if s.keyring == nil {
+		return [][]byte{value}, nil
+	}
+	return s.keyring.encryptAll(value), nil
+}
+
+// unpack returns the original form of a stored value.
+func (s *store) unpack(value []byte) ([]byte, error) {
+	value, err := s.decrypt(value)
+	if err != nil {
+		return nil, err
+	}
+	return s.decompress(value)
+}
+
+// deadLetterKey returns the Redis key of the queue's dead-letter queue. If no dead-letter queue is
+// set but a maximum number of attempts is, tasks are dead-lettered to a queue named after the
+// queue. Otherwise, an empty string is returned.
+func (s *store) deadLetterKey() string {
+	if s.deadLetter != "" {
+		return s.deadLetter
+	}
+	if s.maxAttempts > 0 {
+		return s.keyspace.deadLetter(s.name)
+	}
+	return ""
+}
+
+// undelivered handles a task that was popped from the queue but could not be decoded the same way
+// as popFailed, and returns an error even if the task was re-added to the queue, so that callers
+// can't mistake it for a task that was delivered.
+func (s *store) undelivered(raw []byte, env *Envelope, cause error) error {
+	err := s.popFailed(raw, env, cause)
+	if err != nil {
+		return err
+	}
+	return cause
+}
+
+// popFailed handles a task that was popped from the queue but could not be decoded. The task is
+// dead-lettered if a dead-letter queue is set, dropped if retries are disabled, and otherwise
+// re-added to the end of the queue until it reaches its maximum number of attempts. The task's key
+// was released when it was popped, and is claimed again if the task is re-added.
+func (s *store) popFailed(raw []byte, env *Envelope, cause error) error {
+	if s.deadLetter != "" {
+		return deadLetter(s.ctx, s.redis, s.deadLetter, raw, cause)
+	}
+	if s.noRetry {
+		return cause
+	}
+	exhausted := s.exhausted(env, cause)
+	value := raw
+	if env != nil {
+		var err error
+		value, err = s.reseal(env)
+		if err != nil {
+			return err
+		}
+	}
+	if exhausted {
+		return deadLetter(s.ctx, s.redis, s.deadLetterKey(), value, cause)
+	}
+	key := ""
+	if env != nil {
+		key = env.Key
+	}
+	added, err := requeueScript.Run(s.ctx, s.redis, []string{s.queue, s.unique}, value, key).Int64()
+	if err != nil {
+		return errors.Wrap(err, "failed to re-add task to queue after unmarshal failure")
+	}
+	if added == 0 {
+		return fmt.Errorf("failed to re-add task to queue after unmarshal failure")
+	}
+	return nil
+}
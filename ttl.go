@@ -0,0 +1,64 @@
+package taskqueue
+
+import (
+	"fmt"
+	"time"
+)
+
+// addWithTTL adds task values to the end of the queue in envelopes that expire after ttl.
+func (s *store) addWithTTL(ttl time.Duration, payloads [][]byte) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(ttl)
+	values := make([]any, 0, len(payloads))
+	for _, payload := range payloads {
+		env, err := newEnvelope(payload)
+		if err != nil {
+			return err
+		}
+		env.ExpiresAt = &expiresAt
+		value, err := s.reseal(env)
+		if err != nil {
+			return err
+		}
+		values = append(values, value)
+	}
+	added, err := s.redis.RPush(s.ctx, s.queue, values...).Result()
+	if err != nil {
+		return err
+	}
+	if added == 0 {
+		return fmt.Errorf("failed to add tasks to queue")
+	}
+	return nil
+}
+
+// expire determines if a stored value taken from the queue has expired, in which case it is
+// discarded, or moved to the expired queue if one is set. If the value was reserved under a lease,
+// it is also removed from the consumer's processing list.
+func (s *store) expire(raw []byte, lease string) (bool, error) {
+	_, env, err := s.open(raw)
+	if err != nil || !env.expired() {
+		return false, nil
+	}
+	if lease != "" {
+		keys := []string{s.processing, s.leases, s.unique}
+		err = ackScript.Run(s.ctx, s.redis, keys, raw, lease).Err()
+		if err != nil {
+			return true, err
+		}
+	}
+	if s.expired != "" {
+		err = s.redis.RPush(s.ctx, s.expired, raw).Err()
+		if err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// expired determines if a task stored in the envelope has passed its expiry time.
+func (env *Envelope) expired() bool {
+	return env != nil && env.ExpiresAt != nil && !time.Now().Before(*env.ExpiresAt)
+}
@@ -0,0 +1,89 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_AddWithTTL(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.AddWithTTL(time.Millisecond*50, "stale1", "stale2"))
+	require.NoError(t, queue.AddWithTTL(time.Minute, "fresh1"))
+	require.NoError(t, queue.Add("plain"))
+	require.NoError(t, queue.AddWithTTL(time.Millisecond*50, "stale3"))
+	require.NoError(t, queue.AddWithTTL(time.Minute, "fresh2", "fresh3"))
+	time.Sleep(time.Millisecond * 100)
+
+	value := queue.Pop()
+	require.NotNil(t, value)
+	assert.Equal(t, "fresh1", *value)
+	value = queue.Pop()
+	require.NotNil(t, value)
+	assert.Equal(t, "plain", *value)
+	popped, err := queue.BlockingPop(context.Background(), time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "fresh2", popped)
+	task, err := queue.Reserve()
+	require.NoError(t, err)
+	assert.Equal(t, "fresh3", task.String())
+	assert.NotNil(t, task.Envelope().ExpiresAt)
+	require.NoError(t, task.Ack())
+	assert.Nil(t, queue.Pop())
+}
+
+func TestJSONTaskQueue_AddWithTTL(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	expired := name + ":expired"
+	queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithExpiredQueue(expired))
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	require.NoError(t, queue.AddWithTTL(time.Millisecond*50, 1, 2))
+	require.NoError(t, queue.AddWithTTL(time.Minute, 3))
+	time.Sleep(time.Millisecond * 100)
+
+	var value int
+	require.NoError(t, queue.Pop(&value))
+	assert.Equal(t, 3, value)
+	assert.ErrorIs(t, queue.Pop(&value), taskqueue.ErrEmpty)
+	assert.Equal(t, int64(2), queue.Redis.LLen(context.Background(), expired).Val())
+
+	t.Run("reserved tasks are removed from processing", func(t *testing.T) {
+		require.NoError(t, queue.AddWithTTL(time.Millisecond*50, 4))
+		time.Sleep(time.Millisecond * 100)
+		_, err := queue.BlockingReserve(context.Background(), time.Second)
+		assert.ErrorIs(t, err, taskqueue.ErrTimeout)
+		assert.Equal(t, int64(3), queue.Redis.LLen(context.Background(), expired).Val())
+		assert.Equal(t, zero, queue.Size())
+	})
+}
@@ -0,0 +1,180 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TypedQueue implements a FIFO task queue of values of type T. Tasks are stored the same way as
+// they are by a JSONTaskQueue, so a TypedQueue and a JSONTaskQueue with the same name read and
+// write the same tasks.
+type TypedQueue[T any] struct {
+	queue *JSONTaskQueue
+}
+
+// NewTyped creates a new TypedQueue instance.
+func NewTyped[T any](name string, option ...Option) (*TypedQueue[T], error) {
+	queue, err := NewJSON(name, option...)
+	if err != nil {
+		return nil, err
+	}
+	return &TypedQueue[T]{queue: queue}, nil
+}
+
+// JSON returns the JSONTaskQueue the queue is built on.
+func (q *TypedQueue[T]) JSON() *JSONTaskQueue {
+	return q.queue
+}
+
+// Size returns the number of items in the queue.
+func (q *TypedQueue[T]) Size() uint64 {
+	return q.queue.Size()
+}
+
+// Has determines if a queue has an given task.
+func (q *TypedQueue[T]) Has(task T) bool {
+	return q.queue.Has(task)
+}
+
+// Add adds any number of tasks to the queue in order.
+func (q *TypedQueue[T]) Add(tasks ...T) error {
+	return q.queue.Add(anySlice(tasks)...)
+}
+
+// AddBatch adds any number of tasks to the queue in order with a single command, and returns the
+// number of tasks added.
+func (q *TypedQueue[T]) AddBatch(tasks ...T) (int64, error) {
+	return q.queue.AddBatch(anySlice(tasks)...)
+}
+
+// AddUnique adds a task to the queue unless a task added with the same key is still pending, and
+// determines if it was added.
+func (q *TypedQueue[T]) AddUnique(key string, task T) (bool, error) {
+	return q.queue.AddUnique(key, task)
+}
+
+// AddWithTTL adds any number of tasks to the queue in order, which expire once ttl has elapsed.
+func (q *TypedQueue[T]) AddWithTTL(ttl time.Duration, tasks ...T) error {
+	return q.queue.AddWithTTL(ttl, anySlice(tasks)...)
+}
+
+// AddAt adds any number of tasks to the queue in order once the given time is reached.
+func (q *TypedQueue[T]) AddAt(at time.Time, tasks ...T) error {
+	return q.queue.AddAt(at, anySlice(tasks)...)
+}
+
+// AddIn adds any number of tasks to the queue in order once the given duration has elapsed.
+func (q *TypedQueue[T]) AddIn(delay time.Duration, tasks ...T) error {
+	return q.queue.AddIn(delay, anySlice(tasks)...)
+}
+
+// Pop removes and returns the first task from the queue. If the queue is empty, ErrEmpty is
+// returned. Unlike JSONTaskQueue.Pop, an error is always returned if the task can't be unmarshaled,
+// even if it was re-added to the queue.
+func (q *TypedQueue[T]) Pop() (T, error) {
+	var value T
+	popped, err := q.queue.store.pop()
+	if err != nil {
+		return value, err
+	}
+	err = q.unmarshal(popped, &value)
+	return value, err
+}
+
+// PopN removes and returns up to n tasks from the front of the queue in a single round trip. If some
+// tasks can't be unmarshaled, the rest are returned along with a *BatchError, and the tasks that
+// failed are left as zero values.
+func (q *TypedQueue[T]) PopN(n int) ([]T, error) {
+	var values []T
+	err := q.queue.PopN(n, &values)
+	return values, err
+}
+
+// BlockingPop removes and returns the first task from the queue, waiting for a task to be added if
+// the queue is empty. If no task arrives before the timeout elapses, ErrTimeout is returned. If ctx
+// is cancelled first, the context's error is returned. A timeout of zero waits indefinitely. Redis
+// waits in steps of one second, so a timeout or cancellation may take up to a second longer to be
+// noticed.
+func (q *TypedQueue[T]) BlockingPop(ctx context.Context, timeout time.Duration) (T, error) {
+	var value T
+	popped, err := q.queue.store.blockingPop(ctx, timeout)
+	if err != nil {
+		return value, err
+	}
+	err = q.unmarshal(popped, &value)
+	return value, err
+}
+
+// Get retrieves an item from the queue based on its index.
+func (q *TypedQueue[T]) Get(index int64) (T, error) {
+	var value T
+	stored, err := q.queue.Redis.LIndex(q.queue.ctx, q.queue.store.queue, index).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return value, fmt.Errorf("index %d does not exist in queue", index)
+		}
+		return value, err
+	}
+	payload, _, err := q.queue.store.open(stored)
+	if err != nil {
+		return value, err
+	}
+	err = q.queue.codec.Unmarshal(payload, &value)
+	return value, err
+}
+
+// Peek returns the first task of the queue without removing it. If the queue is empty, ErrEmpty is
+// returned.
+func (q *TypedQueue[T]) Peek() (T, error) {
+	var value T
+	err := q.queue.Peek(&value)
+	return value, err
+}
+
+// Range returns the tasks of the queue from index start to stop, inclusive, without removing them.
+func (q *TypedQueue[T]) Range(start, stop int64) ([]T, error) {
+	var values []T
+	err := q.queue.Range(start, stop, &values)
+	return values, err
+}
+
+// List returns a Cursor that iterates over the tasks of the queue, pageSize tasks at a time,
+// without removing them.
+func (q *TypedQueue[T]) List(pageSize int64) *Cursor {
+	return q.queue.List(pageSize)
+}
+
+// Remove removes a task from the queue.
+func (q *TypedQueue[T]) Remove(task T) error {
+	return q.queue.Remove(task)
+}
+
+// RemoveIndex atomically removes the task at an index of the queue and returns it.
+func (q *TypedQueue[T]) RemoveIndex(index int64) (T, error) {
+	var value T
+	err := q.queue.RemoveIndex(index, &value)
+	return value, err
+}
+
+// Clear removes all tasks from the queue, including scheduled tasks.
+func (q *TypedQueue[T]) Clear() error {
+	return q.queue.Clear()
+}
+
+// unmarshal unmarshals a popped task. If the task can't be unmarshaled, it is handled the same way
+// as it would be by JSONTaskQueue.Pop, and the unmarshal error is returned.
+func (q *TypedQueue[T]) unmarshal(popped []byte, value *T) error {
+	return q.queue.deliver(popped, value)
+}
+
+// anySlice converts a slice of any type to a slice of empty interfaces.
+func anySlice[T any](values []T) []any {
+	result := make([]any, len(values))
+	for i, value := range values {
+		result[i] = value
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type TypedValue struct {
+	String string `json:"string"`
+	Int    int    `json:"int"`
+}
+
+func TestTypedQueue(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewTyped[TypedValue](name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	t.Run("add", func(t *testing.T) {
+		err := queue.Add(TypedValue{"one", 1}, TypedValue{"two", 2}, TypedValue{"three", 3})
+		require.NoError(t, err)
+		assert.Equal(t, uint64(3), queue.Size())
+	})
+	t.Run("get", func(t *testing.T) {
+		value, err := queue.Get(1)
+		require.NoError(t, err)
+		assert.Equal(t, TypedValue{"two", 2}, value)
+		_, err = queue.Get(5)
+		require.Error(t, err)
+	})
+	t.Run("has", func(t *testing.T) {
+		assert.True(t, queue.Has(TypedValue{"three", 3}))
+		assert.False(t, queue.Has(TypedValue{"four", 4}))
+	})
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, queue.Remove(TypedValue{"three", 3}))
+		assert.Equal(t, uint64(2), queue.Size())
+	})
+	t.Run("pop", func(t *testing.T) {
+		value, err := queue.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, TypedValue{"one", 1}, value)
+		value, err = queue.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, TypedValue{"two", 2}, value)
+	})
+	t.Run("pop empty", func(t *testing.T) {
+		_, err := queue.Pop()
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+	})
+	t.Run("blocking pop", func(t *testing.T) {
+		go func() {
+			time.Sleep(time.Millisecond * 100)
+			queue.Add(TypedValue{"four", 4})
+		}()
+		value, err := queue.BlockingPop(context.Background(), time.Second*5)
+		require.NoError(t, err)
+		assert.Equal(t, TypedValue{"four", 4}, value)
+	})
+	t.Run("shares storage with json queue", func(t *testing.T) {
+		require.NoError(t, queue.JSON().Add(TypedValue{"five", 5}))
+		value, err := queue.Pop()
+		require.NoError(t, err)
+		assert.Equal(t, TypedValue{"five", 5}, value)
+	})
+}
+
+func TestTypedQueue_UnmarshalError(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	t.Run("with retry", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewTyped[RetryValue](name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add(RetryValue{"value"}))
+		_, err = queue.Pop()
+		require.Error(t, err)
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+	t.Run("with dead-letter queue", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewTyped[RetryValue](name, ctx, addr, taskqueue.WithDeadLetterQueue(name+":dlq"))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add(RetryValue{"value"}))
+		_, err = queue.Pop()
+		var dlErr *taskqueue.DeadLetterError
+		require.ErrorAs(t, err, &dlErr)
+		assert.Equal(t, zero, queue.Size())
+	})
+}
@@ -0,0 +1,89 @@
+package taskqueue
+
+import (
+	"github.com/redis/go-redis/v9"
+)
+
+// uniqueLua defines the functions shared by scripts that track the keys of pending tasks. The hash
+// at the key passed to each function maps "key:" followed by each pending key to the SHA-1 digest
+// of its task's stored value, and "task:" followed by each digest to its key, so that a key is
+// released by the same script that consumes its task, without decoding the stored value.
+const uniqueLua = `
+local function claim(unique, key, value)
+	local digest = redis.sha1hex(value)
+	if redis.call("HSETNX", unique, "key:" .. key, digest) == 0 then
+		return false
+	end
+	redis.call("HSET", unique, "task:" .. digest, key)
+	return true
+end
+
+local function release(unique, value)
+	local digest = redis.sha1hex(value)
+	local key = redis.call("HGET", unique, "task:" .. digest)
+	if not key then
+		return
+	end
+	redis.call("HDEL", unique, "task:" .. digest)
+	if redis.call("HGET", unique, "key:" .. key) == digest then
+		redis.call("HDEL", unique, "key:" .. key)
+	end
+end
+
+local function remap(unique, value, replacement)
+	local digest = redis.sha1hex(value)
+	local key = redis.call("HGET", unique, "task:" .. digest)
+	if not key then
+		return
+	end
+	local replaced = redis.sha1hex(replacement)
+	redis.call("HDEL", unique, "task:" .. digest)
+	redis.call("HSET", unique, "task:" .. replaced, key, "key:" .. key, replaced)
+end
+`
+
+// uniqueAddScript adds a task to the end of a queue only if its key isn't already pending, and
+// returns 1 if it was added.
+var uniqueAddScript = redis.NewScript(uniqueLua + `
+if not claim(KEYS[2], ARGV[1], ARGV[2]) then
+	return 0
+end
+redis.call("RPUSH", KEYS[1], ARGV[2])
+return 1
+`)
+
+// removeScript removes the first occurrence of a value from a list, releasing its key.
+var removeScript = redis.NewScript(uniqueLua + `
+local removed = redis.call("LREM", KEYS[1], 1, ARGV[1])
+if removed > 0 then
+	release(KEYS[2], ARGV[1])
+end
+return removed
+`)
+
+// addUnique adds a task value to the queue unless a task with the same key is pending, and
+// determines if it was added. The key is also stored in the task's envelope. It is released by the
+// same command that consumes the task, so a consumer that stops afterwards can't leave it pending.
+func (s *store) addUnique(key string, payload []byte) (bool, error) {
+	env, err := newEnvelope(payload)
+	if err != nil {
+		return false, err
+	}
+	env.Key = key
+	value, err := s.reseal(env)
+	if err != nil {
+		return false, err
+	}
+	added, err := uniqueAddScript.Run(s.ctx, s.redis, []string{s.queue, s.unique}, key, value).Int()
+	if err != nil {
+		return false, err
+	}
+	return added == 1, nil
+}
+
+// hasUnique determines if any task added with a key is pending. Such tasks are stored in envelopes,
+// so they can only be found by reading and comparing stored values.
+func (s *store) hasUnique() bool {
+	count, err := s.redis.HLen(s.ctx, s.unique).Result()
+	return err == nil && count > 0
+}
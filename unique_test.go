@@ -0,0 +1,231 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBasicTaskQueue_AddUnique(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewBasic(name, ctx, addr)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		queue.Clear()
+	})
+
+	t.Run("add once", func(t *testing.T) {
+		added, err := queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+		added, err = queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.False(t, added)
+		assert.Equal(t, uint64(1), queue.Size())
+		assert.True(t, queue.Has("value"))
+	})
+	t.Run("released on pop", func(t *testing.T) {
+		value := queue.Pop()
+		require.NotNil(t, value)
+		assert.Equal(t, "value", *value)
+		added, err := queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("released on remove", func(t *testing.T) {
+		require.NoError(t, queue.Remove("value"))
+		assert.Equal(t, zero, queue.Size())
+		added, err := queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("held until acknowledged", func(t *testing.T) {
+		task, err := queue.Reserve()
+		require.NoError(t, err)
+		assert.Equal(t, "key", task.Envelope().Key)
+		require.NoError(t, task.Nack())
+		task, err = queue.Reserve()
+		require.NoError(t, err)
+		added, err := queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.False(t, added)
+		require.NoError(t, task.Ack())
+		added, err = queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("released on blocking pop", func(t *testing.T) {
+		require.NoError(t, queue.Clear())
+		added, err := queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		require.True(t, added)
+		value, err := queue.BlockingPop(context.Background(), time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "value", value)
+		added, err = queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("released on dead letter", func(t *testing.T) {
+		require.NoError(t, queue.Clear())
+		dlq, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithDeadLetterQueue(name+":dlq"))
+		require.NoError(t, err)
+		added, err := dlq.AddUnique("key", "value")
+		require.NoError(t, err)
+		require.True(t, added)
+		task, err := dlq.Reserve()
+		require.NoError(t, err)
+		require.NoError(t, task.DeadLetter(fmt.Errorf("failed")))
+		added, err = queue.AddUnique("key", "value")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("has and remove alongside plain tasks", func(t *testing.T) {
+		require.NoError(t, queue.Clear())
+		require.NoError(t, queue.Add("one", "two", "three"))
+		added, err := queue.AddUnique("key", "four")
+		require.NoError(t, err)
+		require.True(t, added)
+		assert.True(t, queue.Has("two"))
+		assert.True(t, queue.Has("four"))
+		assert.False(t, queue.Has("five"))
+		require.NoError(t, queue.Remove("two"))
+		require.NoError(t, queue.Remove("four"))
+		assert.Equal(t, uint64(2), queue.Size())
+		assert.False(t, queue.Has("four"))
+		added, err = queue.AddUnique("key", "four")
+		require.NoError(t, err)
+		assert.True(t, added)
+	})
+	t.Run("concurrent", func(t *testing.T) {
+		require.NoError(t, queue.Clear())
+		var wg sync.WaitGroup
+		var count int32
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				added, err := queue.AddUnique("concurrent", fmt.Sprint(i))
+				if err == nil && added {
+					atomic.AddInt32(&count, 1)
+				}
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), count)
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+}
+
+func TestJSONTaskQueue_AddUnique(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	type Value struct {
+		ID int `json:"id"`
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	queue, err := taskqueue.NewJSON(name, ctx, addr)
+	require.NoError(t, err)
+	defer queue.Clear()
+
+	added, err := queue.AddUnique("webhook-1", Value{1})
+	require.NoError(t, err)
+	assert.True(t, added)
+	added, err = queue.AddUnique("webhook-1", Value{1})
+	require.NoError(t, err)
+	assert.False(t, added)
+	require.NoError(t, queue.Add(Value{2}))
+
+	var value Value
+	require.NoError(t, queue.Get(0, &value))
+	assert.Equal(t, Value{1}, value)
+	require.NoError(t, queue.Pop(&value))
+	assert.Equal(t, Value{1}, value)
+	require.NoError(t, queue.Pop(&value))
+	assert.Equal(t, Value{2}, value)
+
+	added, err = queue.AddUnique("webhook-1", Value{1})
+	require.NoError(t, err)
+	assert.True(t, added)
+
+	t.Run("held when re-added after a failure", func(t *testing.T) {
+		require.NoError(t, queue.Clear())
+		added, err := queue.AddUnique("webhook-2", "not a value")
+		require.NoError(t, err)
+		require.True(t, added)
+		var value Value
+		require.Error(t, queue.BlockingPop(context.Background(), time.Second, &value))
+		assert.Equal(t, uint64(1), queue.Size())
+		added, err = queue.AddUnique("webhook-2", Value{2})
+		require.NoError(t, err)
+		assert.False(t, added)
+	})
+}
+
+// stoppingCodec is a JSONCodec that cancels a context when a task is unmarshaled, as if the consumer
+// stopped while handling it.
+type stoppingCodec struct {
+	taskqueue.JSONCodec
+	stop context.CancelFunc
+}
+
+func (c stoppingCodec) Unmarshal(data []byte, value any) error {
+	c.stop()
+	return c.JSONCodec.Unmarshal(data, value)
+}
+
+func TestAddUnique_ConsumerStops(t *testing.T) {
+	mr := RunT(t)
+	var addr taskqueue.Option
+	if UseMini {
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+	producer, err := taskqueue.NewJSON(name, addr)
+	require.NoError(t, err)
+	defer producer.Clear()
+	c, stop := context.WithCancel(context.Background())
+	consumer, err := taskqueue.NewJSON(name, addr, taskqueue.WithContext(c), taskqueue.WithCodec(stoppingCodec{stop: stop}))
+	require.NoError(t, err)
+
+	added, err := producer.AddUnique("webhook", 1)
+	require.NoError(t, err)
+	require.True(t, added)
+	// Once the task is popped, the consumer can't send any more commands, so the key must have been
+	// released by the pop itself.
+	var value int
+	require.NoError(t, consumer.Pop(&value))
+	assert.Equal(t, 1, value)
+	added, err = producer.AddUnique("webhook", 1)
+	require.NoError(t, err)
+	assert.True(t, added)
+}
@@ -0,0 +1,213 @@
+package taskqueue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Reserver is a queue from which tasks can be reserved, such as a BasicTaskQueue or JSONTaskQueue.
+// ReserveContext must stop waiting and return the context's error once ctx is done.
+type Reserver interface {
+	ReserveContext(ctx context.Context) (*Task, error)
+}
+
+// HandlerFunc handles a task reserved by a Worker. If it returns nil, the task is acknowledged.
+// Otherwise, the task fails with the returned error, and is retried or dead-lettered according to
+// the queue's options.
+type HandlerFunc func(ctx context.Context, task *Task) error
+
+// Handle calls f(ctx, task).
+func (f HandlerFunc) Handle(ctx context.Context, task *Task) error {
+	return f(ctx, task)
+}
+
+// Handler handles a task reserved by a Worker, the same way as a HandlerFunc.
+type Handler interface {
+	Handle(ctx context.Context, task *Task) error
+}
+
+// Middleware wraps a Handler with behavior that applies to every task, such as logging or
+// recovering from panics.
+type Middleware func(Handler) Handler
+
+// WorkerOptions configure a Worker.
+type WorkerOptions struct {
+	Concurrency int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+}
+
+type WorkerOption func(*WorkerOptions)
+
+// WithConcurrency sets the number of tasks a Worker handles at once. By default, a Worker handles
+// one task at a time.
+func WithConcurrency(concurrency int) WorkerOption {
+	return func(opts *WorkerOptions) {
+		opts.Concurrency = concurrency
+	}
+}
+
+// WithBackoff sets how long a Worker waits before checking an empty queue again. The wait starts
+// at min and doubles each time the queue is still empty, up to max. By default, a Worker waits
+// between 100 milliseconds and 5 seconds.
+func WithBackoff(min, max time.Duration) WorkerOption {
+	return func(opts *WorkerOptions) {
+		opts.MinBackoff = min
+		opts.MaxBackoff = max
+	}
+}
+
+// Worker reserves tasks from a queue and handles them with a HandlerFunc.
+type Worker struct {
+	queue       Reserver
+	handler     Handler
+	middleware  []Middleware
+	concurrency int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+// NewWorker creates a new Worker that handles tasks reserved from queue with handler.
+func NewWorker(queue Reserver, handler HandlerFunc, option ...WorkerOption) (*Worker, error) {
+	options := &WorkerOptions{
+		Concurrency: 1,
+		MinBackoff:  time.Millisecond * 100,
+		MaxBackoff:  time.Second * 5,
+	}
+	for _, setter := range option {
+		setter(options)
+	}
+	if queue == nil || handler == nil {
+		return nil, fmt.Errorf("a worker requires a queue and a handler")
+	}
+	if options.Concurrency < 1 {
+		return nil, fmt.Errorf("a worker's concurrency must be at least 1")
+	}
+	if options.MinBackoff <= 0 || options.MaxBackoff < options.MinBackoff {
+		return nil, fmt.Errorf("invalid back-off between %s and %s", options.MinBackoff, options.MaxBackoff)
+	}
+	worker := &Worker{
+		queue:       queue,
+		handler:     handler,
+		concurrency: options.Concurrency,
+		minBackoff:  options.MinBackoff,
+		maxBackoff:  options.MaxBackoff,
+	}
+	return worker, nil
+}
+
+// Use adds middleware that wraps the worker's handler. Middleware is applied in the order it is
+// added, so the first middleware added is the first to see each task. Use must not be called while
+// the worker is running.
+func (w *Worker) Use(middleware ...Middleware) {
+	w.middleware = append(w.middleware, middleware...)
+}
+
+// Run handles tasks until ctx is cancelled. Once cancelled, no new tasks are reserved, and Run
+// returns the context's error after every task being handled is finished. Handlers are called with
+// a context that carries ctx's values, but isn't cancelled with it, so that tasks in progress can
+// finish. If the queue has a visibility timeout, the lease of each task is renewed while it is
+// handled. Errors reserving, acknowledging, or failing tasks are ignored.
+func (w *Worker) Run(ctx context.Context) error {
+	handler := w.handler
+	for i := len(w.middleware) - 1; i >= 0; i-- {
+		handler = w.middleware[i](handler)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.consume(ctx, handler)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// consume reserves and handles tasks one at a time until ctx is cancelled.
+func (w *Worker) consume(ctx context.Context, handler Handler) {
+	handlerCtx := detachedContext{ctx}
+	backoff := w.minBackoff
+	for ctx.Err() == nil {
+		task, err := w.queue.ReserveContext(ctx)
+		if err != nil {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+			case <-timer.C:
+			}
+			backoff *= 2
+			if backoff > w.maxBackoff {
+				backoff = w.maxBackoff
+			}
+			continue
+		}
+		backoff = w.minBackoff
+		stop := w.renew(task)
+		err = handler.Handle(handlerCtx, task)
+		stop()
+		if err != nil {
+			task.Fail(err)
+		} else {
+			task.Ack()
+		}
+	}
+}
+
+// renew extends the lease of a task being handled until the returned function is called, so that
+// a task whose handler runs longer than the visibility timeout isn't reaped and handled twice. The
+// lease is renewed every third of the visibility timeout, and renewal stops if the lease is lost.
+func (w *Worker) renew(task *Task) func() {
+	visibility := task.store.visibility
+	if visibility <= 0 {
+		return func() {}
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(visibility / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if errors.Is(task.Extend(visibility), ErrNotHeld) {
+					return
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// detachedContext carries the values of its parent context, but is never cancelled.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
+
+func (c detachedContext) Value(key any) any {
+	return c.parent.Value(key)
+}
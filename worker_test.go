@@ -0,0 +1,179 @@
+package taskqueue_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	taskqueue "github.com/stellaraf/go-task-queue"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorker(t *testing.T) {
+	mr := RunT(t)
+	var ctx, addr taskqueue.Option
+	if UseMini {
+		ctx = taskqueue.WithContext(mr.Ctx)
+		addr = taskqueue.WithHost(mr.Addr())
+	} else {
+		ctx = taskqueue.WithContext(Ctx)
+		addr = taskqueue.WithHost(Addr)
+	}
+
+	t.Run("handles tasks", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+		for i := 0; i < 10; i++ {
+			require.NoError(t, queue.Add(fmt.Sprint(i)))
+		}
+
+		var mu sync.Mutex
+		handled := map[string]bool{}
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handler := func(ctx context.Context, task *taskqueue.Task) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled[task.String()] = true
+			if len(handled) == 10 {
+				cancel()
+			}
+			return nil
+		}
+		worker, err := taskqueue.NewWorker(queue, handler, taskqueue.WithConcurrency(3))
+		require.NoError(t, err)
+		err = worker.Run(c)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Len(t, handled, 10)
+		assert.Equal(t, zero, queue.Size())
+		_, err = queue.Reserve()
+		assert.ErrorIs(t, err, taskqueue.ErrEmpty)
+	})
+
+	t.Run("failed tasks are retried", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewJSON(name, ctx, addr, taskqueue.WithMaxAttempts(3))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add("value"))
+
+		attempts := 0
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		handler := func(ctx context.Context, task *taskqueue.Task) error {
+			attempts++
+			if attempts == 3 {
+				cancel()
+			}
+			return fmt.Errorf("attempt %d failed", attempts)
+		}
+		worker, err := taskqueue.NewWorker(queue, handler, taskqueue.WithBackoff(time.Millisecond, time.Millisecond*10))
+		require.NoError(t, err)
+		worker.Run(c)
+		assert.Equal(t, 3, attempts)
+		assert.Equal(t, zero, queue.Size())
+		assert.Equal(t, int64(1), queue.Redis.LLen(context.Background(), name+":dead-letter").Val())
+	})
+
+	t.Run("waits for tasks in progress", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add("one", "two"))
+
+		c, cancel := context.WithCancel(context.Background())
+		finished := 0
+		handler := func(ctx context.Context, task *taskqueue.Task) error {
+			cancel()
+			time.Sleep(time.Millisecond * 100)
+			assert.NoError(t, ctx.Err())
+			finished++
+			return nil
+		}
+		worker, err := taskqueue.NewWorker(queue, handler)
+		require.NoError(t, err)
+		worker.Run(c)
+		assert.Equal(t, 1, finished)
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+
+	t.Run("stops waiting for the rate limit", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr, taskqueue.WithRateLimit(0.2, 1))
+		require.NoError(t, err)
+		defer queue.Clear()
+		require.NoError(t, queue.Add("one", "two"))
+
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		var mu sync.Mutex
+		handled := 0
+		handler := func(ctx context.Context, task *taskqueue.Task) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled++
+			// The next token isn't available for 5 seconds.
+			time.AfterFunc(time.Millisecond*100, cancel)
+			return nil
+		}
+		worker, err := taskqueue.NewWorker(queue, handler)
+		require.NoError(t, err)
+		start := time.Now()
+		assert.ErrorIs(t, worker.Run(c), context.Canceled)
+		assert.Less(t, time.Since(start), time.Second*2)
+		mu.Lock()
+		assert.Equal(t, 1, handled)
+		mu.Unlock()
+		assert.Equal(t, uint64(1), queue.Size())
+	})
+
+	t.Run("renews leases", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		visibility := taskqueue.WithVisibilityTimeout(time.Millisecond * 150)
+		queue, err := taskqueue.NewBasic(name, ctx, addr, visibility)
+		require.NoError(t, err)
+		defer queue.Clear()
+		reaper, err := taskqueue.NewReaper(name, ctx, addr)
+		require.NoError(t, err)
+		require.NoError(t, queue.Add("slow"))
+
+		c, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		reaped := 0
+		handler := func(ctx context.Context, task *taskqueue.Task) error {
+			// The handler runs for several visibility timeouts while the reaper checks for
+			// expired leases.
+			for i := 0; i < 10; i++ {
+				time.Sleep(time.Millisecond * 50)
+				n, err := reaper.Reap()
+				assert.NoError(t, err)
+				reaped += n
+			}
+			cancel()
+			return nil
+		}
+		worker, err := taskqueue.NewWorker(queue, handler)
+		require.NoError(t, err)
+		assert.ErrorIs(t, worker.Run(c), context.Canceled)
+		assert.Equal(t, 0, reaped)
+		assert.Equal(t, zero, queue.Size())
+	})
+	t.Run("invalid options", func(t *testing.T) {
+		name := fmt.Sprintf("%s--%s", t.Name(), time.Now().Format(time.RFC3339Nano))
+		queue, err := taskqueue.NewBasic(name, ctx, addr)
+		require.NoError(t, err)
+		handler := func(ctx context.Context, task *taskqueue.Task) error { return nil }
+		_, err = taskqueue.NewWorker(queue, nil)
+		require.Error(t, err)
+		_, err = taskqueue.NewWorker(queue, handler, taskqueue.WithConcurrency(0))
+		require.Error(t, err)
+		_, err = taskqueue.NewWorker(queue, handler, taskqueue.WithBackoff(time.Second, time.Millisecond))
+		require.Error(t, err)
+	})
+}